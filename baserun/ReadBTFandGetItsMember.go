@@ -6,192 +6,479 @@ package baserun
 import (
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
 	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Yinzhongkan399/GoServerPS/btf"
 )
 
-// ReadBTFandGetItsMember 导出函数：读取 ./.cache/btf.json，寻找与 sk_buff 相关的 types（深度5），
-// 并把所有参数中包含这些类型的 FUNC 项保存到 ./.cache/relatedFuncD5.json，返回这些函数项。
-func ReadBTFandGetItsMember() ([]map[string]interface{}, error) {
-	btfPath := "./.cache/btf.json"
-	raw, err := os.ReadFile(btfPath)
+// RelatedFunc 是 relatedFuncD5.json / FuncIDMap.json 中的一项，字段与 bpftool -j btf dump 的 FUNC 项相同，
+// 模块中的函数另外带有 module 与 btf_id，seeds 为该函数与之相关的种子类型。
+type RelatedFunc struct {
+	ID      uint64         `json:"id"`
+	Kind    string         `json:"kind,omitempty"`
+	Name    string         `json:"name"`
+	TypeID  btf.TypeID     `json:"type_id,omitempty"`
+	Linkage string         `json:"linkage,omitempty"`
+	Module  string         `json:"module,omitempty"`
+	BTFID   btf.TypeID     `json:"btf_id,omitempty"` // 模块 split BTF 中的原始 ID
+	Seeds   []string       `json:"seeds,omitempty"`
+	Return  string         `json:"return,omitempty"` // 返回值的 C 类型
+	Params  []RelatedParam `json:"params,omitempty"`
+}
+
+// RelatedParam 是相关函数中类型属于相关类型的一个参数。
+type RelatedParam struct {
+	Index    int         `json:"index"` // 从 0 开始，探针中对应 PT_REGS_PARM<index+1>
+	Name     string      `json:"name,omitempty"`
+	Type     string      `json:"type"` // 参数的 C 类型
+	Seed     string      `json:"seed"`
+	Distance int         `json:"distance"`
+	Chain    []ChainLink `json:"chain"` // 从参数类型到种子的引用路径，最后一项为种子
+}
+
+// ChainLink 是引用路径上的一个类型。Member 为该 struct/union 中通向下一项的成员名，
+// FUNC_PROTO 为 "return" 或参数名（无名时为 "arg<序号>"）；匿名成员为空。
+type ChainLink struct {
+	Type   string `json:"type"`
+	Member string `json:"member,omitempty"`
+}
+
+// RelatedType 是与种子类型相关的一个类型。模块中的类型 ID 只在该模块内唯一。
+type RelatedType struct {
+	ID     btf.TypeID `json:"id"`
+	Kind   string     `json:"kind"`
+	Name   string     `json:"name,omitempty"`
+	Module string     `json:"module,omitempty"`
+	// Distance 是与种子的距离：经过的 STRUCT/UNION/FUNC_PROTO 层数，种子本身为 0。
+	Distance int `json:"distance"`
+}
+
+// SeedResult 是一个种子类型的相关类型与相关函数。
+type SeedResult struct {
+	Seed  string        `json:"seed"`
+	Types []RelatedType `json:"types,omitempty"`
+	Funcs []RelatedFunc `json:"funcs"`
+}
+
+// 各模块的 split BTF 都从 vmlinux 的最后一个 ID 之后编号，不同模块的 ID 会重复。
+// 模块函数的 id 因此写成 模块键<<moduleIDShift | btf_id，保证在 FuncIDMap 与探针中唯一；
+// 模块键由模块名决定（见 moduleKeys），加载、卸载其他模块不会改变它。
+// id 小于 2^53，JSON 客户端按浮点数读取也不会丢失精度。
+const (
+	moduleIDShift = 32
+	moduleKeyBits = 20
+)
+
+// moduleKeys 为每个模块分配 [1, 2^moduleKeyBits] 中的键：模块名的 FNV-1a 哈希折叠到 moduleKeyBits 位。
+// 哈希冲突时按模块名顺序顺延到下一个空闲的键，只有冲突的模块之一出现或消失时才会变化。
+func moduleKeys(mods []*btf.Spec) map[string]uint64 {
+	names := make([]string, 0, len(mods))
+	for _, mod := range mods {
+		names = append(names, mod.Module)
+	}
+	sort.Strings(names)
+	const n = 1 << moduleKeyBits
+	keys := make(map[string]uint64, len(names))
+	used := make(map[uint64]bool, len(names))
+	for _, name := range names {
+		h := fnv.New32a()
+		h.Write([]byte(name))
+		k := uint64(h.Sum32()) % n
+		for used[k] {
+			k = (k + 1) % n
+		}
+		used[k] = true
+		keys[name] = k + 1
+	}
+	return keys
+}
+
+// ReadBTFandGetItsMember 以当前内核的 BTF 运行 (&Options{}).ReadBTFandGetItsMember。
+func ReadBTFandGetItsMember() ([]RelatedFunc, error) {
+	return (&Options{}).ReadBTFandGetItsMember()
+}
+
+// ReadBTFandGetItsMember 导出函数：解析 o.BTFFile（默认 /sys/kernel/btf/vmlinux）以及 o.ModuleDir 中模块的
+// split BTF，寻找与 o.Seeds（默认 sk_buff）相关的 types（距离不超过 o.Depth，默认 5），并把所有参数中包含这些类型的 FUNC 项
+// 保存到 ./.cache/relatedFuncD5.json，返回这些函数项。多个种子的结果按函数合并，seeds 记录来源。
+// 同一份 BTF（按内容、内核版本与种子区分）的结果缓存在 .cache/btf/<release>-<sha256>/ 中，再次运行时直接复用。
+func (o *Options) ReadBTFandGetItsMember() ([]RelatedFunc, error) {
+	src, err := o.readSources()
 	if err != nil {
-		return nil, fmt.Errorf("failed to read %s: %w", btfPath, err)
+		return nil, err
 	}
+	if err := ensureCache(cacheDir); err != nil {
+		return nil, err
+	}
+	relatedFunc, err := src.relatedFuncs()
+	if err != nil {
+		return nil, err
+	}
+	outPath := filepath.Join(cacheDir, relatedFuncFile)
+	if err := copyFile(filepath.Join(src.dir(), relatedFuncFile), outPath); err != nil {
+		return nil, fmt.Errorf("failed to write %s: %w", outPath, err)
+	}
+	return relatedFunc, nil
+}
 
-	var btfFile map[string]interface{}
-	if err := json.Unmarshal(raw, &btfFile); err != nil {
-		return nil, fmt.Errorf("invalid json in %s: %w", btfPath, err)
+// RelatedFuncs 返回与 ReadBTFandGetItsMember 相同的函数项，但不写 ./.cache/relatedFuncD5.json，
+// 只使用（并填充）o 的 BTF、种子与距离上限对应的缓存目录，适合按请求覆盖种子的查询。
+func (o *Options) RelatedFuncs() ([]RelatedFunc, error) {
+	src, err := o.readSources()
+	if err != nil {
+		return nil, err
 	}
+	return src.relatedFuncs()
+}
 
-	typesIface, ok := btfFile["types"]
-	if !ok {
-		return nil, fmt.Errorf("no 'types' key in %s", btfPath)
+// relatedFuncs 返回 src 的相关函数：缓存目录中已有 relatedFuncD5.json 时直接读取，否则计算后写入缓存目录。
+// 不读写 .cache 顶层的文件，因此结果总是与 src 的 BTF、种子和距离上限对应。
+func (src *btfSources) relatedFuncs() ([]RelatedFunc, error) {
+	cached := filepath.Join(src.dir(), relatedFuncFile)
+	if _, err := os.Stat(cached); err == nil {
+		return readRelatedFuncs(cached)
 	}
 
-	typesList, ok := typesIface.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("'types' is not an array in %s", btfPath)
+	spec, mods, err := src.parse()
+	if err != nil {
+		return nil, err
 	}
+	results, err := relatedBySeed(spec, mods, src.seeds, src.depth)
+	if err != nil {
+		return nil, err
+	}
+	relatedFunc := mergeSeedFuncs(results)
 
-	// build list and map[id]item
-	L1List := make([]map[string]interface{}, 0, len(typesList))
-	L1Dict := make(map[int]map[string]interface{})
-	for _, it := range typesList {
-		m, ok := it.(map[string]interface{})
-		if !ok {
-			continue
-		}
-		L1List = append(L1List, m)
-		if idVal, found := m["id"]; found {
-			if id := toInt(idVal); id >= 0 {
-				L1Dict[id] = m
-			}
-		}
+	outRaw, err := json.MarshalIndent(relatedFunc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal related func: %w", err)
+	}
+	if err := src.save(relatedFuncFile, outRaw); err != nil {
+		return nil, fmt.Errorf("failed to cache %s: %w", relatedFuncFile, err)
 	}
+	return relatedFunc, nil
+}
 
-	// find sk_buff id
-	skbid := -1
-	for _, item := range L1List {
-		if nameVal, ok := item["name"]; ok {
-			if nameStr, ok := nameVal.(string); ok && nameStr == "sk_buff" {
-				if idVal, ok := item["id"]; ok {
-					skbid = toInt(idVal)
-					break
-				}
+// mergeSeedFuncs 把各种子的相关函数按函数合并，seeds 与 params 依种子顺序追加。
+func mergeSeedFuncs(results []SeedResult) []RelatedFunc {
+	relatedFunc := make([]RelatedFunc, 0)
+	index := make(map[uint64]int)
+	for _, res := range results {
+		for _, rf := range res.Funcs {
+			if i, ok := index[rf.ID]; ok {
+				relatedFunc[i].Seeds = append(relatedFunc[i].Seeds, res.Seed)
+				relatedFunc[i].Params = append(relatedFunc[i].Params, rf.Params...)
+				continue
 			}
+			index[rf.ID] = len(relatedFunc)
+			relatedFunc = append(relatedFunc, rf)
 		}
 	}
-	if skbid < 0 {
-		return nil, fmt.Errorf("sk_buff not found in btf types")
+	return relatedFunc
+}
+
+func readRelatedFuncs(path string) ([]RelatedFunc, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var funcs []RelatedFunc
+	if err := json.Unmarshal(b, &funcs); err != nil {
+		return nil, fmt.Errorf("invalid json in %s: %w", path, err)
 	}
+	return funcs, nil
+}
 
-	related := make(map[int]struct{})
-	related[skbid] = struct{}{}
+// Related 对 o.Seeds 中的每个种子类型分别计算相关类型与相关函数。结果不写入缓存。
+func (o *Options) Related() ([]SeedResult, error) {
+	src, err := o.readSources()
+	if err != nil {
+		return nil, err
+	}
+	spec, mods, err := src.parse()
+	if err != nil {
+		return nil, err
+	}
+	return relatedBySeed(spec, mods, src.seeds, src.depth)
+}
 
-	updated := true
-	depth := 0
-	for updated && depth < 5 {
-		updated = false
-		for _, item := range L1List {
-			id := -1
-			if idv, ok := item["id"]; ok {
-				id = toInt(idv)
-			}
-			if id < 0 {
-				continue
-			}
-			if _, exists := related[id]; exists {
-				continue
+// relatedBySeed 为每个种子先在 vmlinux 中求相关类型，再在各模块中以 vmlinux 的结果为基础继续扩展。
+// 只在某个模块中定义的种子（例如 nf_conntrack 的 nf_conn）从该模块自己的类型开始。
+func relatedBySeed(spec *btf.Spec, mods []*btf.Spec, seeds []string, depth int) ([]SeedResult, error) {
+	keys := moduleKeys(mods)
+	results := make([]SeedResult, 0, len(seeds))
+	for _, seed := range seeds {
+		res := SeedResult{Seed: seed}
+
+		related := newTypeSet(nil)
+		found := related.addSeed(spec, seed)
+		findRelatedTypes(spec, related, depth)
+		res.Types = related.types(spec)
+		res.Funcs = findRelatedFuncs(spec, related, seed, 0)
+
+		for _, mod := range mods {
+			// 模块类型的 ID 只在本模块内有效，每个模块在 vmlinux 结果之上单独扩展
+			modRelated := newTypeSet(related)
+			if modRelated.addSeed(mod, seed) {
+				found = true
 			}
-			kind, _ := item["kind"].(string)
-			switch kind {
-			case "STRUCT":
-				if members, ok := item["members"].([]interface{}); ok {
-					for _, mv := range members {
-						if mm, ok := mv.(map[string]interface{}); ok {
-							if tidv, ok := mm["type_id"]; ok {
-								if tid := toInt(tidv); tid >= 0 {
-									if _, ok := related[tid]; ok {
-										related[id] = struct{}{}
-										updated = true
-										break
-									}
-								}
-							}
-						}
-					}
-				}
-			case "ARRAY", "VOLATILE", "CONST", "PTR":
-				if tidv, ok := item["type_id"]; ok {
-					if tid := toInt(tidv); tid >= 0 {
-						if _, ok := related[tid]; ok {
-							related[id] = struct{}{}
-							updated = true
-						}
-					}
-				}
+			findRelatedTypes(mod, modRelated, depth)
+			res.Types = append(res.Types, modRelated.types(mod)...)
+			res.Funcs = append(res.Funcs, findRelatedFuncs(mod, modRelated, seed, keys[mod.Module])...)
+		}
+		if !found {
+			return nil, fmt.Errorf("seed type %q not found in btf types", seed)
+		}
+		results = append(results, res)
+	}
+	return results, nil
+}
+
+// seedKinds 是种子名匹配多个类型时的优先顺序。
+var seedKinds = []btf.Kind{btf.KindStruct, btf.KindUnion, btf.KindTypedef, btf.KindEnum, btf.KindEnum64}
+
+// seedType 返回 spec 自己定义的（split BTF 中不含 Base 的）名为 name 的类型。
+func seedType(spec *btf.Spec, name string) btf.Type {
+	for _, kind := range seedKinds {
+		for _, t := range spec.TypesByName(name) {
+			if t.Kind() == kind && t.ID() >= spec.FirstID {
+				return t
 			}
 		}
-		depth++
 	}
+	return nil
+}
+
+// typeSet 是相关类型 ID 到其与种子距离的映射；模块的集合以 vmlinux 的集合为 parent。
+// via 记录每个类型通向种子的下一跳，种子本身没有。
+type typeSet struct {
+	ids    map[btf.TypeID]int
+	via    map[btf.TypeID]hop
+	parent *typeSet
+}
+
+// hop 是 typeSet.via 中的一项：经由成员（或 FUNC_PROTO 的参数、返回值）member 引用 next。
+type hop struct {
+	next   btf.TypeID
+	member string
+}
+
+func newTypeSet(parent *typeSet) *typeSet {
+	return &typeSet{ids: make(map[btf.TypeID]int), via: make(map[btf.TypeID]hop), parent: parent}
+}
 
-	// find related functions
-	relatedFunc := make([]map[string]interface{}, 0)
-	for _, item := range L1List {
-		kind, _ := item["kind"].(string)
-		if kind != "FUNC" {
+// addSeed 把 spec 中名为 seed 的类型以及同名的前向声明以距离 0 加入集合，报告是否找到了种子本身。
+func (s *typeSet) addSeed(spec *btf.Spec, seed string) bool {
+	t := seedType(spec, seed)
+	if t == nil {
+		return false
+	}
+	s.ids[t.ID()] = 0
+	for _, fwd := range spec.TypesByName(seed) {
+		if fwd.Kind() == btf.KindFwd && fwd.ID() >= spec.FirstID {
+			s.ids[fwd.ID()] = 0
+		}
+	}
+	return true
+}
+
+// types 按距离、ID 顺序返回集合自身（不含 parent）中的类型。
+func (s *typeSet) types(spec *btf.Spec) []RelatedType {
+	out := make([]RelatedType, 0, len(s.ids))
+	for id, dist := range s.ids {
+		t, err := spec.TypeByID(id)
+		if err != nil {
 			continue
 		}
-		// look up type proto
-		tpid := -1
-		if tidv, ok := item["type_id"]; ok {
-			tpid = toInt(tidv)
+		out = append(out, RelatedType{ID: id, Kind: t.Kind().String(), Name: t.TypeName(), Module: spec.Module, Distance: dist})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Distance != out[j].Distance {
+			return out[i].Distance < out[j].Distance
 		}
-		p, ok := L1Dict[tpid]
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// distance 返回 id 与种子的距离（含 parent 中的类型）。
+func (s *typeSet) distance(id btf.TypeID) (int, bool) {
+	for ; s != nil; s = s.parent {
+		if d, ok := s.ids[id]; ok {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+func (s *typeSet) has(t btf.Type) bool {
+	_, ok := s.distance(t.ID())
+	return ok
+}
+
+// chain 返回从 t 沿 via 到种子的路径。spec 用于解析 ID，模块中的路径可以延伸到 vmlinux。
+func (s *typeSet) chain(spec *btf.Spec, t btf.Type) []ChainLink {
+	var out []ChainLink
+	seen := make(map[btf.TypeID]bool)
+	for t != nil && !seen[t.ID()] {
+		seen[t.ID()] = true
+		h, ok := s.hop(t.ID())
+		out = append(out, ChainLink{Type: btf.CType(t), Member: h.member})
 		if !ok {
-			continue
+			break
+		}
+		next, err := spec.TypeByID(h.next)
+		if err != nil {
+			break
+		}
+		t = next
+	}
+	return out
+}
+
+func (s *typeSet) hop(id btf.TypeID) (hop, bool) {
+	for ; s != nil; s = s.parent {
+		if h, ok := s.via[id]; ok {
+			return h, true
+		}
+	}
+	return hop{}, false
+}
+
+// viaMember 返回 u 中引用 id 的成员名（见 ChainLink）。
+func viaMember(u btf.Type, id btf.TypeID) string {
+	var members []btf.Member
+	switch v := u.(type) {
+	case *btf.Struct:
+		members = v.Members
+	case *btf.Union:
+		members = v.Members
+	case *btf.FuncProto:
+		if v.Return.ID() == id {
+			return "return"
 		}
-		if pk, _ := p["kind"].(string); pk == "FUNC_PROTO" {
-			if params, ok := p["params"].([]interface{}); ok {
-				for _, pv := range params {
-					if pm, ok := pv.(map[string]interface{}); ok {
-						if ptidv, ok := pm["type_id"]; ok {
-							if ptid := toInt(ptidv); ptid >= 0 {
-								if _, ok := related[ptid]; ok {
-									relatedFunc = append(relatedFunc, item)
-									break
-								}
-							}
-						}
-					}
+		for i, p := range v.Params {
+			if p.Type.ID() == id {
+				if p.Name != "" {
+					return p.Name
 				}
+				return fmt.Sprintf("arg%d", i)
 			}
 		}
 	}
-
-	// write out
-	outPath := "./.cache/relatedFuncD5.json"
-	outDir := "./.cache"
-	if _, err := os.Stat(outDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(outDir, 0755); err != nil {
-			return nil, fmt.Errorf("failed to create %s: %w", outDir, err)
+	for _, m := range members {
+		if m.Type.ID() == id {
+			return m.Name
 		}
 	}
+	return ""
+}
 
-	outRaw, err := json.MarshalIndent(relatedFunc, "", "  ")
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal related func: %w", err)
+// hopCost 是经由 t 引用相关类型时增加的距离：每包一层 STRUCT、UNION 或 FUNC_PROTO 算一步，
+// PTR、TYPEDEF、CONST、VOLATILE、RESTRICT、TYPE_TAG、ARRAY、VAR 与被引用的类型距离相同，
+// 因此 const struct sk_buff *restrict、typedef 包装与多级指针都与 sk_buff 本身等价。
+func hopCost(t btf.Type) int {
+	switch t.(type) {
+	case *btf.Struct, *btf.Union, *btf.FuncProto:
+		return 1
 	}
-	if err := os.WriteFile(outPath, outRaw, 0644); err != nil {
-		return nil, fmt.Errorf("failed to write %s: %w", outPath, err)
+	return 0
+}
+
+// findRelatedTypes 沿反向引用边（btf.References）求 related 的不动点：spec 自己的类型只要引用了
+// related 中的类型就加入 related，并记录最短距离（见 hopCost）。depth > 0 时只保留距离不超过 depth 的类型，
+// depth < 0 不限制。
+func findRelatedTypes(spec *btf.Spec, related *typeSet, depth int) {
+	users := make(map[btf.TypeID][]btf.Type)
+	for _, t := range spec.Types {
+		if _, ok := t.(*btf.Func); ok {
+			continue // FUNC 由 findRelatedFuncs 按参数判断
+		}
+		for _, ref := range btf.References(t) {
+			users[ref.ID()] = append(users[ref.ID()], t)
+		}
 	}
 
-	return relatedFunc, nil
+	// 边权只有 0 和 1，按距离分桶处理即可得到最短距离
+	var buckets [][]btf.TypeID
+	push := func(id btf.TypeID, d int) {
+		for len(buckets) <= d {
+			buckets = append(buckets, nil)
+		}
+		buckets[d] = append(buckets[d], id)
+	}
+	for id := range users {
+		if d, ok := related.distance(id); ok {
+			push(id, d)
+		}
+	}
+	for d := 0; d < len(buckets); d++ {
+		for i := 0; i < len(buckets[d]); i++ {
+			id := buckets[d][i]
+			if cur, _ := related.distance(id); cur < d {
+				continue
+			}
+			for _, u := range users[id] {
+				nd := d + hopCost(u)
+				if depth > 0 && nd > depth {
+					continue
+				}
+				if old, ok := related.distance(u.ID()); ok && old <= nd {
+					continue
+				}
+				related.ids[u.ID()] = nd
+				related.via[u.ID()] = hop{next: id, member: viaMember(u, id)}
+				push(u.ID(), nd)
+			}
+		}
+	}
 }
 
-// toInt 从 interface{} 安全转换到 int，失败返回 -1
-func toInt(v interface{}) int {
-	switch vv := v.(type) {
-	case float64:
-		return int(vv)
-	case int:
-		return vv
-	case int64:
-		return int(vv)
-	case json.Number:
-		i64, err := vv.Int64()
-		if err != nil {
-			return -1
+// findRelatedFuncs 返回 spec 中参数含有 related 类型的 FUNC，并标出这些参数。modKey 为模块键，0 表示 vmlinux。
+func findRelatedFuncs(spec *btf.Spec, related *typeSet, seed string, modKey uint64) []RelatedFunc {
+	out := make([]RelatedFunc, 0)
+	for _, t := range spec.Types {
+		fn, ok := t.(*btf.Func)
+		if !ok {
+			continue
+		}
+		var params []RelatedParam
+		for i, param := range fn.Type.Params {
+			dist, ok := related.distance(param.Type.ID())
+			if !ok {
+				continue
+			}
+			params = append(params, RelatedParam{
+				Index:    i,
+				Name:     param.Name,
+				Type:     btf.CType(param.Type),
+				Seed:     seed,
+				Distance: dist,
+				Chain:    related.chain(spec, param.Type),
+			})
+		}
+		if len(params) == 0 {
+			continue
+		}
+		rf := RelatedFunc{
+			ID:      uint64(fn.ID()),
+			Kind:    fn.Kind().String(),
+			Name:    fn.Name,
+			TypeID:  fn.Type.ID(),
+			Linkage: fn.Linkage.String(),
+			Seeds:   []string{seed},
+			Return:  btf.CType(fn.Type.Return),
+			Params:  params,
 		}
-		return int(i64)
-	case string:
-		// 尝试解析数字字符串
-		var i int
-		if _, err := fmt.Sscanf(vv, "%d", &i); err == nil {
-			return i
+		if modKey > 0 {
+			rf.ID = modKey<<moduleIDShift | uint64(fn.ID())
+			rf.Module = spec.Module
+			rf.BTFID = fn.ID()
 		}
-		return -1
-	default:
-		return -1
+		out = append(out, rf)
 	}
+	return out
 }
@@ -2,44 +2,125 @@ package baserun
 
 import (
 	"fmt"
+	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"strings"
+
+	"github.com/Yinzhongkan399/GoServerPS/btf"
 )
 
-// BaseRun performs the same actions as the original baserun.py:
-// - ensure .cache directory exists
-// - remove .cache/FunctionInfo.db and .cache/PacketInfo.db if present
-// - remove ./.cache/btf.json (force)
-// - run `bpftool -j btf dump file /sys/kernel/btf/vmlinux` and write stdout to ./.cache/btf.json
-// Returns an error on failure.
+// Options selects where the pipeline reads BTF from. The zero value uses
+// the running kernel's /sys/kernel/btf.
+type Options struct {
+	// BTFFile is the vmlinux BTF: a raw BTF blob, an ELF file with a .BTF
+	// section, or a BTFHub-style .btf.tar.xz / .tar.gz archive.
+	// Default /sys/kernel/btf/vmlinux.
+	BTFFile string
+	// ModuleDir holds module split BTF files resolved against BTFFile.
+	// Defaults to /sys/kernel/btf when BTFFile is empty; when BTFFile is
+	// set, modules are only read if ModuleDir is given explicitly, since
+	// the local modules do not belong to the other kernel.
+	ModuleDir string
+	// Release names the kernel in the cache key. Defaults to the running
+	// kernel's release, or the BTFFile name without archive suffixes.
+	Release string
+	// Seeds are the type names the related-type closure starts from,
+	// e.g. "sk_buff", "sock", "net_device", "nf_conn". A leading "struct "
+	// is ignored. Default DefaultSeeds.
+	Seeds []string
+	// Depth limits the related-type closure to types at most Depth
+	// struct/union/function-prototype levels away from a seed. 0 means
+	// DefaultDepth, a negative value means no limit.
+	Depth int
+}
+
+// DefaultDepth is five struct/union/function-prototype levels. The
+// original script also ran five rounds, but it spent a round on every
+// PTR, CONST and ARRAY as well, so the same number reaches further here.
+const DefaultDepth = 5
+
+func (o *Options) depth() int {
+	if o.Depth == 0 {
+		return DefaultDepth
+	}
+	if o.Depth < 0 {
+		return -1
+	}
+	return o.Depth
+}
+
+// DefaultSeeds is the seed list of the original script.
+var DefaultSeeds = []string{"sk_buff"}
+
+// seeds returns the normalized, de-duplicated seed list.
+func (o *Options) seeds() []string {
+	var out []string
+	seen := make(map[string]bool)
+	for _, s := range o.Seeds {
+		s = strings.TrimSpace(s)
+		for _, prefix := range []string{"struct ", "union ", "enum "} {
+			s = strings.TrimSpace(strings.TrimPrefix(s, prefix))
+		}
+		if s != "" && !seen[s] {
+			seen[s] = true
+			out = append(out, s)
+		}
+	}
+	if len(out) == 0 {
+		return DefaultSeeds
+	}
+	return out
+}
+
+func (o *Options) btfFile() string {
+	if o.BTFFile == "" {
+		return btf.VmlinuxPath
+	}
+	return o.BTFFile
+}
+
+func (o *Options) moduleDir() string {
+	if o.ModuleDir == "" && o.BTFFile == "" {
+		return btf.ModuleDir
+	}
+	return o.ModuleDir
+}
+
+// BaseRun runs (&Options{}).BaseRun for the running kernel.
 func BaseRun() error {
-	cacheDir := ".cache"
+	return (&Options{}).BaseRun()
+}
+
+// BaseRun prepares the cache directory like the original baserun.py:
+//   - ensure .cache directory exists
+//   - rotate .cache/FunctionInfo.db and .cache/PacketInfo.db into
+//     .cache/archive instead of deleting them (use Purge to delete)
+//   - remove the stale ./.cache/btf.json left by older versions
+//   - check that the BTF source is present
+//
+// The bpftool JSON dump is no longer needed: ReadBTFandGetItsMember reads
+// the raw BTF directly through the btf package, and derived artifacts are
+// cached per BTF under .cache/btf.
+// Returns an error on failure.
+func (o *Options) BaseRun() error {
 	if err := ensureCache(cacheDir); err != nil {
 		return err
 	}
 
-	// remove specific DB files if they exist
-	_ = removeIfExists(filepath.Join(cacheDir, "FunctionInfo.db"))
-	_ = removeIfExists(filepath.Join(cacheDir, "PacketInfo.db"))
+	rotated, err := rotateDBs()
+	for _, p := range rotated {
+		log.Printf("rotated capture database to %s", p)
+	}
+	if err != nil {
+		return err
+	}
 
-	// remove existing btf.json (like `rm -f ./.cache/btf.json`)
+	// btf.json was the bpftool dump used by earlier versions; it can be hundreds of MB
 	_ = removeIfExists(filepath.Join(cacheDir, "btf.json"))
 
-	// create output file with O_EXCL behavior (like Python 'x')
-	outPath := filepath.Join(cacheDir, "btf.json")
-	outFile, err := os.OpenFile(outPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
-	if err != nil {
-		return fmt.Errorf("create output file failed: %w", err)
-	}
-	defer outFile.Close()
-
-	// run bpftool and write stdout into the file
-	cmd := exec.Command("bpftool", "-j", "btf", "dump", "file", "/sys/kernel/btf/vmlinux")
-	cmd.Stdout = outFile
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("bpftool command failed: %w", err)
+	if _, err := os.Stat(o.btfFile()); err != nil {
+		return fmt.Errorf("btf not available: %w", err)
 	}
 
 	return nil
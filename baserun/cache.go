@@ -0,0 +1,271 @@
+package baserun
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Yinzhongkan399/GoServerPS/btf"
+)
+
+// Layout of ./.cache:
+//
+//	relatedFuncD5.json, FuncIDMap.json, kProberFunc.c   artifacts of the last run
+//	btf/<release>-<sha256>/                              the same artifacts, one directory per BTF
+//	FunctionInfo.db, PacketInfo.db                       capture databases
+//	archive/<name>.<timestamp>.db                        databases rotated away by BaseRun
+const (
+	cacheDir        = ".cache"
+	btfCacheDir     = "btf"
+	archiveDir      = "archive"
+	relatedFuncFile = "relatedFuncD5.json"
+	funcIDMapFile   = "FuncIDMap.json"
+	kProberFile     = "kProberFunc.c"
+	cacheMetaFile   = "meta.json"
+
+	// cacheVersion is part of the cache key. Bump it when the artifacts
+	// change for the same inputs, so that stale entries are not restored.
+	// Version 2: FuncIDMap.json and kProberFunc.c are built from the
+	// related functions of the same key rather than the shared file.
+	cacheVersion = 2
+)
+
+var captureDBs = []string{"FunctionInfo.db", "PacketInfo.db"}
+
+// btfSources is the BTF a run works on, read once so that hashing and
+// parsing see the same bytes.
+type btfSources struct {
+	file    string
+	release string
+	vmlinux []byte
+	modules []moduleBlob
+	seeds   []string
+	depth   int
+	sum     string // hex sha256 over cacheVersion, release, seeds, depth, vmlinux and modules
+}
+
+type moduleBlob struct {
+	name string
+	data []byte
+}
+
+// cacheMeta is written next to the cached artifacts.
+type cacheMeta struct {
+	Release string    `json:"release"`
+	SHA256  string    `json:"sha256"`
+	BTFFile string    `json:"btf_file"`
+	Modules []string  `json:"modules,omitempty"`
+	Seeds   []string  `json:"seeds"`
+	Depth   int       `json:"depth"`
+	Created time.Time `json:"created"`
+}
+
+// readSources reads the vmlinux BTF and the module BTF selected by o.
+func (o *Options) readSources() (*btfSources, error) {
+	src := &btfSources{file: o.btfFile(), release: o.release(), seeds: o.seeds(), depth: o.depth()}
+	var err error
+	if src.vmlinux, err = btf.ReadBlob(src.file); err != nil {
+		return nil, fmt.Errorf("failed to load btf: %w", err)
+	}
+	if dir := o.moduleDir(); dir != "" {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load module btf: %w", err)
+		}
+		for _, e := range entries {
+			if e.Name() == "vmlinux" || e.IsDir() {
+				continue
+			}
+			b, err := os.ReadFile(filepath.Join(dir, e.Name()))
+			if err != nil {
+				return nil, fmt.Errorf("failed to load module btf: %w", err)
+			}
+			src.modules = append(src.modules, moduleBlob{name: e.Name(), data: b})
+		}
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\x00%s\x00%s\x00%d\x00", cacheVersion, src.release, strings.Join(src.seeds, ","), src.depth)
+	h.Write(src.vmlinux)
+	for _, m := range src.modules {
+		io.WriteString(h, "\x00"+m.name+"\x00")
+		h.Write(m.data)
+	}
+	src.sum = hex.EncodeToString(h.Sum(nil))
+	return src, nil
+}
+
+// parse parses the blobs read by readSources.
+func (src *btfSources) parse() (*btf.Spec, []*btf.Spec, error) {
+	spec, err := btf.Parse(src.vmlinux)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load btf: %s: %w", src.file, err)
+	}
+	mods := make([]*btf.Spec, 0, len(src.modules))
+	for _, m := range src.modules {
+		mod, err := btf.ParseSplit(m.data, spec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to load module btf: %s: %w", m.name, err)
+		}
+		mod.Module = m.name
+		mods = append(mods, mod)
+	}
+	return spec, mods, nil
+}
+
+// dir returns the cache directory for this BTF, e.g.
+// .cache/btf/6.8.0-45-generic-3f2a9c0d1b7e4a55.
+func (src *btfSources) dir() string {
+	return filepath.Join(cacheDir, btfCacheDir, src.release+"-"+src.sum[:16])
+}
+
+func (src *btfSources) writeMeta() error {
+	meta := cacheMeta{Release: src.release, SHA256: src.sum, BTFFile: src.file, Seeds: src.seeds, Depth: src.depth, Created: time.Now()}
+	for _, m := range src.modules {
+		meta.Modules = append(meta.Modules, m.name)
+	}
+	b, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(src.dir(), cacheMetaFile), b, 0644)
+}
+
+// store copies the named top-level artifacts into the cache directory.
+func (src *btfSources) store(names ...string) error {
+	if err := os.MkdirAll(src.dir(), 0755); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := copyFile(filepath.Join(cacheDir, name), filepath.Join(src.dir(), name)); err != nil {
+			return err
+		}
+	}
+	return src.writeMeta()
+}
+
+// save writes b as the named artifact into the cache directory only.
+func (src *btfSources) save(name string, b []byte) error {
+	if err := os.MkdirAll(src.dir(), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(src.dir(), name), b, 0644); err != nil {
+		return err
+	}
+	return src.writeMeta()
+}
+
+// restore copies cached artifacts back to the top level. It reports false
+// if any of them is missing, in which case nothing is copied.
+func (src *btfSources) restore(names ...string) (bool, error) {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(src.dir(), name)); err != nil {
+			return false, nil
+		}
+	}
+	for _, name := range names {
+		if err := copyFile(filepath.Join(src.dir(), name), filepath.Join(cacheDir, name)); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// release returns Options.Release, or the running kernel's release for the
+// live BTF, or the BTF file name without archive suffixes
+// ("5.15.0-91-generic.btf.tar.xz" -> "5.15.0-91-generic").
+func (o *Options) release() string {
+	rel := o.Release
+	if rel == "" && o.BTFFile == "" {
+		if b, err := os.ReadFile("/proc/sys/kernel/osrelease"); err == nil {
+			rel = strings.TrimSpace(string(b))
+		}
+	}
+	if rel == "" {
+		rel = filepath.Base(o.btfFile())
+		for _, suffix := range []string{".xz", ".txz", ".gz", ".tgz", ".tar", ".btf"} {
+			rel = strings.TrimSuffix(rel, suffix)
+		}
+	}
+	return strings.NewReplacer("/", "_", string(os.PathSeparator), "_").Replace(rel)
+}
+
+// rotateDBs moves non-empty capture databases to .cache/archive so that a
+// new run starts with empty ones without losing earlier captures. Empty
+// databases are left in place.
+func rotateDBs() ([]string, error) {
+	var rotated []string
+	for _, name := range captureDBs {
+		path := filepath.Join(cacheDir, name)
+		fi, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if fi.Size() == 0 {
+			continue // nothing to keep; the new run writes into it
+		}
+		if err := os.MkdirAll(filepath.Join(cacheDir, archiveDir), 0755); err != nil {
+			return rotated, err
+		}
+		ext := filepath.Ext(name)
+		stem := filepath.Join(cacheDir, archiveDir, strings.TrimSuffix(name, ext)+"."+fi.ModTime().Format("20060102-150405"))
+		dst := stem + ext
+		for i := 1; ; i++ {
+			if _, err := os.Lstat(dst); os.IsNotExist(err) {
+				break
+			}
+			dst = fmt.Sprintf("%s-%d%s", stem, i, ext)
+		}
+		if err := os.Rename(path, dst); err != nil {
+			return rotated, fmt.Errorf("rotate %s: %w", path, err)
+		}
+		rotated = append(rotated, dst)
+	}
+	return rotated, nil
+}
+
+// Purge deletes the BTF cache and the current artifacts. With dbs set it
+// also deletes the capture databases and their rotated copies. It returns
+// the removed paths.
+func Purge(dbs bool) ([]string, error) {
+	paths := []string{
+		filepath.Join(cacheDir, btfCacheDir),
+		filepath.Join(cacheDir, relatedFuncFile),
+		filepath.Join(cacheDir, funcIDMapFile),
+		filepath.Join(cacheDir, kProberFile),
+		filepath.Join(cacheDir, "btf.json"),
+	}
+	if dbs {
+		for _, name := range captureDBs {
+			paths = append(paths, filepath.Join(cacheDir, name))
+		}
+		paths = append(paths, filepath.Join(cacheDir, archiveDir))
+	}
+	var removed []string
+	for _, p := range paths {
+		if _, err := os.Lstat(p); err != nil {
+			continue
+		}
+		if err := os.RemoveAll(p); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", p, err)
+		}
+		removed = append(removed, p)
+	}
+	sort.Strings(removed)
+	return removed, nil
+}
+
+func copyFile(src, dst string) error {
+	b, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(dst, b, 0644)
+}
@@ -0,0 +1,215 @@
+package baserun
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Yinzhongkan399/GoServerPS/internal/btftest"
+)
+
+// inTempDir runs the test in an empty directory, since the cache lives
+// in the relative ./.cache.
+func inTempDir(t *testing.T) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir(cacheDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotateDBs(t *testing.T) {
+	inTempDir(t)
+	mtime := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	funcDB := filepath.Join(cacheDir, "FunctionInfo.db")
+	packetDB := filepath.Join(cacheDir, "PacketInfo.db")
+	writeFile(t, funcDB, "")
+	for _, want := range []string{"PacketInfo.20260102-030405.db", "PacketInfo.20260102-030405-1.db"} {
+		writeFile(t, packetDB, "rows of "+want)
+		if err := os.Chtimes(packetDB, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+		rotated, err := rotateDBs()
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst := filepath.Join(cacheDir, archiveDir, want)
+		if !reflect.DeepEqual(rotated, []string{dst}) {
+			t.Fatalf("rotateDBs() = %v, want [%s]", rotated, dst)
+		}
+		if got := readFile(t, dst); got != "rows of "+want {
+			t.Errorf("%s = %q", dst, got)
+		}
+		if _, err := os.Stat(packetDB); !os.IsNotExist(err) {
+			t.Errorf("%s still exists after rotation", packetDB)
+		}
+		// the empty database stays where it is
+		if fi, err := os.Stat(funcDB); err != nil || fi.Size() != 0 {
+			t.Errorf("empty %s was moved: %v", funcDB, err)
+		}
+	}
+
+	if rotated, err := rotateDBs(); err != nil || len(rotated) != 0 {
+		t.Errorf("rotateDBs() with only empty databases = %v, %v", rotated, err)
+	}
+}
+
+func TestCacheStoreRestore(t *testing.T) {
+	inTempDir(t)
+	src := &btfSources{file: "vmlinux", release: "6.8.0-test", seeds: []string{"sk_buff"}, depth: 5, sum: strings.Repeat("0123456789abcdef", 4)}
+	if want := filepath.Join(".cache", "btf", "6.8.0-test-0123456789abcdef"); src.dir() != want {
+		t.Fatalf("dir() = %s, want %s", src.dir(), want)
+	}
+
+	if ok, err := src.restore(funcIDMapFile); ok || err != nil {
+		t.Fatalf("restore from an empty cache = %v, %v", ok, err)
+	}
+
+	top := func(name string) string { return filepath.Join(cacheDir, name) }
+	writeFile(t, top(funcIDMapFile), "ids")
+	writeFile(t, top(kProberFile), "probes")
+	if err := src.store(funcIDMapFile, kProberFile); err != nil {
+		t.Fatal(err)
+	}
+	var meta cacheMeta
+	if err := json.Unmarshal([]byte(readFile(t, filepath.Join(src.dir(), cacheMetaFile))), &meta); err != nil {
+		t.Fatal(err)
+	}
+	if meta.Release != src.release || meta.SHA256 != src.sum || !reflect.DeepEqual(meta.Seeds, src.seeds) || meta.Depth != 5 {
+		t.Errorf("meta.json = %+v", meta)
+	}
+
+	writeFile(t, top(funcIDMapFile), "other kernel")
+	os.Remove(top(kProberFile))
+	if ok, err := src.restore(funcIDMapFile, kProberFile); !ok || err != nil {
+		t.Fatalf("restore = %v, %v", ok, err)
+	}
+	if got := readFile(t, top(funcIDMapFile)); got != "ids" {
+		t.Errorf("restored %s = %q, want ids", funcIDMapFile, got)
+	}
+	if got := readFile(t, top(kProberFile)); got != "probes" {
+		t.Errorf("restored %s = %q, want probes", kProberFile, got)
+	}
+
+	// a partial hit copies nothing
+	writeFile(t, top(funcIDMapFile), "other kernel")
+	if ok, err := src.restore(funcIDMapFile, relatedFuncFile); ok || err != nil {
+		t.Fatalf("restore with a missing artifact = %v, %v", ok, err)
+	}
+	if got := readFile(t, top(funcIDMapFile)); got != "other kernel" {
+		t.Errorf("partial restore overwrote %s with %q", funcIDMapFile, got)
+	}
+
+	// save writes into the cache only
+	if err := src.save(relatedFuncFile, []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, filepath.Join(src.dir(), relatedFuncFile)); got != "[]" {
+		t.Errorf("saved %s = %q", relatedFuncFile, got)
+	}
+	if _, err := os.Stat(top(relatedFuncFile)); !os.IsNotExist(err) {
+		t.Errorf("save wrote %s to the top level", relatedFuncFile)
+	}
+}
+
+func TestReadSourcesKey(t *testing.T) {
+	dir := t.TempDir()
+	b := btftest.New()
+	b.Struct("sk_buff", 0)
+	vmlinux := filepath.Join(dir, "5.15.0-91-generic.btf")
+	writeFile(t, vmlinux, string(b.Bytes()))
+	mods := filepath.Join(dir, "modules")
+	writeFile(t, filepath.Join(mods, "nf_conntrack"), string(btftest.NewSplit(b).Bytes()))
+
+	key := func(o Options) string {
+		t.Helper()
+		src, err := o.readSources()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return src.dir()
+	}
+	base := key(Options{BTFFile: vmlinux})
+	if !strings.HasPrefix(base, filepath.Join(cacheDir, btfCacheDir, "5.15.0-91-generic-")) {
+		t.Errorf("dir() = %s, want the release taken from the file name", base)
+	}
+	if got := key(Options{BTFFile: vmlinux, Seeds: []string{"struct sk_buff"}, Depth: DefaultDepth}); got != base {
+		t.Errorf("equivalent options changed the key: %s vs %s", got, base)
+	}
+	for _, o := range []Options{
+		{BTFFile: vmlinux, Release: "other"},
+		{BTFFile: vmlinux, Seeds: []string{"sock"}},
+		{BTFFile: vmlinux, Depth: 3},
+		{BTFFile: vmlinux, ModuleDir: mods},
+	} {
+		if got := key(o); got == base {
+			t.Errorf("%+v did not change the key %s", o, base)
+		}
+	}
+}
+
+func TestPurge(t *testing.T) {
+	inTempDir(t)
+	files := []string{
+		filepath.Join(cacheDir, btfCacheDir, "6.8.0-0123456789abcdef", funcIDMapFile),
+		filepath.Join(cacheDir, relatedFuncFile),
+		filepath.Join(cacheDir, funcIDMapFile),
+		filepath.Join(cacheDir, kProberFile),
+		filepath.Join(cacheDir, "FunctionInfo.db"),
+		filepath.Join(cacheDir, archiveDir, "PacketInfo.20260102-030405.db"),
+	}
+	for _, f := range files {
+		writeFile(t, f, "x")
+	}
+
+	removed, err := Purge(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(cacheDir, funcIDMapFile),
+		filepath.Join(cacheDir, btfCacheDir),
+		filepath.Join(cacheDir, kProberFile),
+		filepath.Join(cacheDir, relatedFuncFile),
+	}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("Purge(false) = %v, want %v", removed, want)
+	}
+	for _, f := range files[4:] {
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("Purge(false) removed %s", f)
+		}
+	}
+
+	removed, err = Purge(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []string{filepath.Join(cacheDir, "FunctionInfo.db"), filepath.Join(cacheDir, archiveDir)}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("Purge(true) = %v, want %v", removed, want)
+	}
+}
@@ -0,0 +1,183 @@
+//go:build linux
+// +build linux
+
+package baserun
+
+import (
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"testing"
+
+	"github.com/Yinzhongkan399/GoServerPS/btf"
+	"github.com/Yinzhongkan399/GoServerPS/internal/btftest"
+)
+
+// moduleFixture 生成 vmlinux 与两个模块的 split BTF。两个模块都从 vmlinux 之后的 ID 6 开始编号：
+//
+//	vmlinux:      struct sk_buff { int len; };  int f_base(struct sk_buff *skb);
+//	nf_conntrack: struct nf_conn { struct sk_buff *skb; };
+//	              int nf_ct_get(struct nf_conn *ct);      // [9]
+//	              int nf_skb(struct sk_buff *skb);        // [11]
+//	other_mod:    struct foo { int x; };
+//	              void foo_rx(struct foo *f, struct sk_buff *skb); // [9]
+func moduleFixture(t *testing.T) (*btf.Spec, []*btf.Spec) {
+	t.Helper()
+	b := btftest.New()
+	i := b.Int("int", 4, true)                                                                 // [1]
+	skb := b.Struct("sk_buff", 4, btftest.Member{Name: "len", Type: i})                        // [2]
+	skbPtr := b.Ptr(skb)                                                                       // [3]
+	b.Func("f_base", b.FuncProto(i, btftest.Param{Name: "skb", Type: skbPtr}), btftest.Global) // [4] [5]
+
+	nf := btftest.NewSplit(b)
+	conn := nf.Struct("nf_conn", 8, btftest.Member{Name: "skb", Type: skbPtr})                           // [6]
+	nf.Func("nf_ct_get", nf.FuncProto(i, btftest.Param{Name: "ct", Type: nf.Ptr(conn)}), btftest.Global) // [7] [8] [9]
+	nf.Func("nf_skb", nf.FuncProto(i, btftest.Param{Name: "skb", Type: skbPtr}), btftest.Static)         // [10] [11]
+
+	other := btftest.NewSplit(b)
+	foo := other.Struct("foo", 4, btftest.Member{Name: "x", Type: i}) // [6]
+	proto := other.FuncProto(0, btftest.Param{Name: "f", Type: other.Ptr(foo)}, btftest.Param{Name: "skb", Type: skbPtr})
+	other.Func("foo_rx", proto, btftest.Global) // [7] [8] [9]
+
+	spec, err := btf.Parse(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var mods []*btf.Spec
+	for _, m := range []struct {
+		name string
+		b    *btftest.Builder
+	}{{"nf_conntrack", nf}, {"other_mod", other}} {
+		mod, err := btf.ParseSplit(m.b.Bytes(), spec)
+		if err != nil {
+			t.Fatalf("%s: %v", m.name, err)
+		}
+		mod.Module = m.name
+		mods = append(mods, mod)
+	}
+	return spec, mods
+}
+
+func TestRelatedBySeedModules(t *testing.T) {
+	spec, mods := moduleFixture(t)
+	for _, mod := range mods {
+		if mod.Base != spec || mod.FirstID != 6 {
+			t.Fatalf("%s: FirstID = %d, base set %v; want 6 on vmlinux", mod.Module, mod.FirstID, mod.Base == spec)
+		}
+	}
+	keys := moduleKeys(mods)
+	nf, other := keys["nf_conntrack"], keys["other_mod"]
+
+	results, err := relatedBySeed(spec, mods, []string{"sk_buff", "nf_conn"}, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type fn struct {
+		ID     uint64
+		Name   string
+		Module string
+		BTFID  btf.TypeID
+		TypeID btf.TypeID
+		Params []RelatedParam
+	}
+	funcs := func(res SeedResult) []fn {
+		var out []fn
+		for _, f := range res.Funcs {
+			out = append(out, fn{f.ID, f.Name, f.Module, f.BTFID, f.TypeID, f.Params})
+		}
+		return out
+	}
+	param := func(index int, name, typ, seed string, dist int, chain ...string) RelatedParam {
+		p := RelatedParam{Index: index, Name: name, Type: typ, Seed: seed, Distance: dist}
+		for i := 0; i+1 < len(chain); i += 2 {
+			p.Chain = append(p.Chain, ChainLink{Type: chain[i], Member: chain[i+1]})
+		}
+		return p
+	}
+	skbChain := []string{"struct sk_buff *", "", "struct sk_buff", ""}
+
+	// vmlinux 的 sk_buff 在模块中继续扩展；两个模块中的 [9] 靠模块键区分
+	want := []fn{
+		{5, "f_base", "", 0, 4, []RelatedParam{param(0, "skb", "struct sk_buff *", "sk_buff", 0, skbChain...)}},
+		{nf<<32 | 9, "nf_ct_get", "nf_conntrack", 9, 8, []RelatedParam{param(0, "ct", "struct nf_conn *", "sk_buff", 1,
+			append([]string{"struct nf_conn *", "", "struct nf_conn", "skb"}, skbChain...)...)}},
+		{nf<<32 | 11, "nf_skb", "nf_conntrack", 11, 10, []RelatedParam{param(0, "skb", "struct sk_buff *", "sk_buff", 0, skbChain...)}},
+		{other<<32 | 9, "foo_rx", "other_mod", 9, 8, []RelatedParam{param(1, "skb", "struct sk_buff *", "sk_buff", 0, skbChain...)}},
+	}
+	if got := funcs(results[0]); !reflect.DeepEqual(got, want) {
+		t.Errorf("sk_buff funcs = %+v\nwant %+v", got, want)
+	}
+	// 模块的结果只含模块自己的类型，vmlinux 中的 sk_buff 不会在模块中再作为种子出现
+	for _, rt := range results[0].Types {
+		if rt.Module != "" && rt.ID < mods[0].FirstID {
+			t.Errorf("module %s lists vmlinux type %d %s", rt.Module, rt.ID, rt.Name)
+		}
+	}
+
+	// nf_conn 只在 nf_conntrack 中定义：vmlinux 与 other_mod 中没有相关的类型和函数
+	want = []fn{
+		{nf<<32 | 9, "nf_ct_get", "nf_conntrack", 9, 8, []RelatedParam{param(0, "ct", "struct nf_conn *", "nf_conn", 0,
+			"struct nf_conn *", "", "struct nf_conn", "")}},
+	}
+	if got := funcs(results[1]); !reflect.DeepEqual(got, want) {
+		t.Errorf("nf_conn funcs = %+v\nwant %+v", got, want)
+	}
+	wantTypes := []RelatedType{
+		{ID: 6, Kind: "STRUCT", Name: "nf_conn", Module: "nf_conntrack", Distance: 0},
+		{ID: 7, Kind: "PTR", Module: "nf_conntrack", Distance: 0},
+		{ID: 8, Kind: "FUNC_PROTO", Module: "nf_conntrack", Distance: 1},
+	}
+	if got := results[1].Types; !reflect.DeepEqual(got, wantTypes) {
+		t.Errorf("nf_conn types = %+v\nwant %+v", got, wantTypes)
+	}
+
+	if _, err := relatedBySeed(spec, mods, []string{"nf_conn_missing"}, -1); err == nil {
+		t.Error("relatedBySeed with an unknown seed succeeded, want error")
+	}
+}
+
+func TestModuleKeys(t *testing.T) {
+	specs := func(names ...string) []*btf.Spec {
+		var out []*btf.Spec
+		for _, name := range names {
+			out = append(out, &btf.Spec{Module: name})
+		}
+		return out
+	}
+	keys := moduleKeys(specs("nf_conntrack", "other_mod"))
+	if got := moduleKeys(specs("other_mod", "nf_conntrack")); !reflect.DeepEqual(got, keys) {
+		t.Errorf("keys depend on module order: %v vs %v", got, keys)
+	}
+	added := moduleKeys(specs("other_mod", "nf_conntrack", "bridge"))
+	for name, k := range keys {
+		if added[name] != k {
+			t.Errorf("loading bridge changed the key of %s from %d to %d", name, k, added[name])
+		}
+	}
+
+	// 找一对哈希冲突的模块名：按名字排序在前的取哈希值，另一个顺延，且与顺序无关
+	hash := func(name string) uint64 {
+		h := fnv.New32a()
+		h.Write([]byte(name))
+		return uint64(h.Sum32()) % (1 << moduleKeyBits)
+	}
+	seen := make(map[uint64]string)
+	var a, b string
+	for i := 0; a == ""; i++ {
+		name := fmt.Sprintf("mod%d", i)
+		if prev, ok := seen[hash(name)]; ok {
+			a, b = prev, name
+			if b < a {
+				a, b = b, a
+			}
+		}
+		seen[hash(name)] = name
+	}
+	for _, order := range [][]string{{a, b}, {b, a}} {
+		got := moduleKeys(specs(order...))
+		ka, kb := hash(a)+1, (hash(a)+1)%(1<<moduleKeyBits)+1
+		if got[a] != ka || got[b] != kb {
+			t.Errorf("colliding %v: keys = %v, want %s=%d %s=%d", order, got, a, ka, b, kb)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package baserun
+
+import "github.com/Yinzhongkan399/GoServerPS/btf"
+
+// Query parses the vmlinux and module BTF selected by o and returns a
+// btf.Query over them. Its indexes are built on the first query.
+func (o *Options) Query() (*btf.Query, error) {
+	src, err := o.readSources()
+	if err != nil {
+		return nil, err
+	}
+	spec, mods, err := src.parse()
+	if err != nil {
+		return nil, err
+	}
+	return btf.NewQuery(spec, mods...), nil
+}
@@ -0,0 +1,231 @@
+//go:build linux
+// +build linux
+
+package baserun
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Yinzhongkan399/GoServerPS/btf"
+	"github.com/Yinzhongkan399/GoServerPS/internal/btftest"
+)
+
+// relatedFixture 生成以 sk_buff 为种子的测试 BTF，返回 spec 与各类型的 ID：
+//
+//	struct sk_buff { int len; };
+//	typedef struct sk_buff skb_t;
+//	struct wrap1 { struct sk_buff *skb; };
+//	struct wrap2 { struct wrap1 *w; };
+//	struct wrap3 { struct wrap2 *w; };
+//	struct both { struct wrap1 w; struct sk_buff *skb; }; // 两条路径，取较短的 skb
+//	union u { int i; skb_t s; };
+//	struct other { int x; };
+//	int f_restrict(const struct sk_buff *restrict skb, int len);
+//	void f_pp(struct other *o, struct sk_buff **pskb);
+//	struct wrap1 *f_wrap(struct wrap2 *w);
+//	int f_far(struct wrap3 *w);
+//	int f_typedef(skb_t *);
+//	int f_none(struct other *o);
+func relatedFixture(t *testing.T) (*btf.Spec, map[string]btf.TypeID) {
+	t.Helper()
+	b := btftest.New()
+	ids := make(map[string]uint32)
+	ids["int"] = b.Int("int", 4, true)
+	ids["sk_buff"] = b.Struct("sk_buff", 4, btftest.Member{Name: "len", Type: ids["int"]})
+	ids["sk_buff *"] = b.Ptr(ids["sk_buff"])
+	ids["skb_t"] = b.Typedef("skb_t", ids["sk_buff"])
+	ids["const sk_buff"] = b.Const(ids["sk_buff"])
+	ids["const sk_buff *"] = b.Ptr(ids["const sk_buff"])
+	ids["const sk_buff *restrict"] = b.Restrict(ids["const sk_buff *"])
+	ids["sk_buff **"] = b.Ptr(ids["sk_buff *"])
+	ids["wrap1"] = b.Struct("wrap1", 8, btftest.Member{Name: "skb", Type: ids["sk_buff *"]})
+	ids["wrap1 *"] = b.Ptr(ids["wrap1"])
+	ids["wrap2"] = b.Struct("wrap2", 8, btftest.Member{Name: "w", Type: ids["wrap1 *"]})
+	ids["wrap2 *"] = b.Ptr(ids["wrap2"])
+	ids["wrap3"] = b.Struct("wrap3", 8, btftest.Member{Name: "w", Type: ids["wrap2 *"]})
+	ids["wrap3 *"] = b.Ptr(ids["wrap3"])
+	ids["both"] = b.Struct("both", 16,
+		btftest.Member{Name: "w", Type: ids["wrap1"]},
+		btftest.Member{Name: "skb", Type: ids["sk_buff *"], BitOffset: 64})
+	ids["u"] = b.Union("u", 4,
+		btftest.Member{Name: "i", Type: ids["int"]},
+		btftest.Member{Name: "s", Type: ids["skb_t"]})
+	ids["other"] = b.Struct("other", 4, btftest.Member{Name: "x", Type: ids["int"]})
+	ids["other *"] = b.Ptr(ids["other"])
+	ids["skb_t *"] = b.Ptr(ids["skb_t"])
+
+	funcs := []struct {
+		name   string
+		ret    uint32
+		params []btftest.Param
+	}{
+		{"f_restrict", ids["int"], []btftest.Param{{Name: "skb", Type: ids["const sk_buff *restrict"]}, {Name: "len", Type: ids["int"]}}},
+		{"f_pp", 0, []btftest.Param{{Name: "o", Type: ids["other *"]}, {Name: "pskb", Type: ids["sk_buff **"]}}},
+		{"f_wrap", ids["wrap1 *"], []btftest.Param{{Name: "w", Type: ids["wrap2 *"]}}},
+		{"f_far", ids["int"], []btftest.Param{{Name: "w", Type: ids["wrap3 *"]}}},
+		{"f_typedef", ids["int"], []btftest.Param{{Type: ids["skb_t *"]}}},
+		{"f_none", ids["int"], []btftest.Param{{Name: "o", Type: ids["other *"]}}},
+	}
+	for _, f := range funcs {
+		ids[f.name+" proto"] = b.FuncProto(f.ret, f.params...)
+		ids[f.name] = b.Func(f.name, ids[f.name+" proto"], btftest.Global)
+	}
+
+	spec, err := btf.Parse(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]btf.TypeID, len(ids))
+	for k, v := range ids {
+		out[k] = btf.TypeID(v)
+	}
+	return spec, out
+}
+
+func TestFindRelatedTypes(t *testing.T) {
+	spec, ids := relatedFixture(t)
+	dists := map[string]int{
+		"sk_buff":                 0,
+		"sk_buff *":               0,
+		"skb_t":                   0,
+		"const sk_buff":           0,
+		"const sk_buff *":         0,
+		"const sk_buff *restrict": 0,
+		"sk_buff **":              0,
+		"skb_t *":                 0,
+		"wrap1":                   1,
+		"wrap1 *":                 1,
+		"both":                    1,
+		"u":                       1,
+		"f_restrict proto":        1,
+		"f_pp proto":              1,
+		"f_typedef proto":         1,
+		"wrap2":                   2,
+		"wrap2 *":                 2,
+		"f_wrap proto":            2, // 返回值 wrap1 * 为 1，参数 wrap2 * 为 2，取较小者再加 1
+		"wrap3":                   3,
+		"wrap3 *":                 3,
+		"f_far proto":             4,
+	}
+	for _, tt := range []struct {
+		depth int
+		max   int
+	}{{-1, 4}, {2, 2}, {3, 3}} {
+		related := newTypeSet(nil)
+		if !related.addSeed(spec, "sk_buff") {
+			t.Fatal("seed sk_buff not found")
+		}
+		findRelatedTypes(spec, related, tt.depth)
+
+		want := make(map[btf.TypeID]int)
+		for name, d := range dists {
+			if d <= tt.max {
+				want[ids[name]] = d
+			}
+		}
+		got := make(map[btf.TypeID]int)
+		for _, rt := range related.types(spec) {
+			got[rt.ID] = rt.Distance
+		}
+		for name, id := range ids {
+			if g, w := lookup(got, id), lookup(want, id); g != w {
+				t.Errorf("depth %d: %s (id %d) distance = %v, want %v", tt.depth, name, id, g, w)
+			}
+		}
+	}
+}
+
+// lookup 返回 id 的距离，不在集合中时为 "absent"。
+func lookup(m map[btf.TypeID]int, id btf.TypeID) interface{} {
+	if d, ok := m[id]; ok {
+		return d
+	}
+	return "absent"
+}
+
+func TestRelatedChain(t *testing.T) {
+	spec, ids := relatedFixture(t)
+	related := newTypeSet(nil)
+	related.addSeed(spec, "sk_buff")
+	findRelatedTypes(spec, related, -1)
+
+	tests := []struct {
+		from string
+		want []ChainLink
+	}{
+		{"sk_buff", []ChainLink{{Type: "struct sk_buff"}}},
+		{"const sk_buff *restrict", []ChainLink{
+			{Type: "const struct sk_buff *restrict"},
+			{Type: "const struct sk_buff *"},
+			{Type: "const struct sk_buff"},
+			{Type: "struct sk_buff"},
+		}},
+		{"sk_buff **", []ChainLink{{Type: "struct sk_buff **"}, {Type: "struct sk_buff *"}, {Type: "struct sk_buff"}}},
+		{"u", []ChainLink{{Type: "union u", Member: "s"}, {Type: "skb_t"}, {Type: "struct sk_buff"}}},
+		{"both", []ChainLink{{Type: "struct both", Member: "skb"}, {Type: "struct sk_buff *"}, {Type: "struct sk_buff"}}},
+		{"wrap2 *", []ChainLink{
+			{Type: "struct wrap2 *"},
+			{Type: "struct wrap2", Member: "w"},
+			{Type: "struct wrap1 *"},
+			{Type: "struct wrap1", Member: "skb"},
+			{Type: "struct sk_buff *"},
+			{Type: "struct sk_buff"},
+		}},
+		{"f_wrap proto", []ChainLink{
+			{Type: "struct wrap1 *(struct wrap2 *w)", Member: "return"},
+			{Type: "struct wrap1 *"},
+			{Type: "struct wrap1", Member: "skb"},
+			{Type: "struct sk_buff *"},
+			{Type: "struct sk_buff"},
+		}},
+		{"f_typedef proto", []ChainLink{{Type: "int (skb_t *)", Member: "arg0"}, {Type: "skb_t *"}, {Type: "skb_t"}, {Type: "struct sk_buff"}}},
+	}
+	for _, tt := range tests {
+		typ, err := spec.TypeByID(ids[tt.from])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := related.chain(spec, typ); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("chain(%s) = %+v, want %+v", tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestFindRelatedFuncs(t *testing.T) {
+	spec, ids := relatedFixture(t)
+	results, err := relatedBySeed(spec, nil, []string{"sk_buff"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skb := []ChainLink{{Type: "struct sk_buff *"}, {Type: "struct sk_buff"}}
+	fn := func(name, ret string, params ...RelatedParam) RelatedFunc {
+		return RelatedFunc{
+			ID: uint64(ids[name]), Kind: "FUNC", Name: name, TypeID: ids[name+" proto"], Linkage: "global",
+			Seeds: []string{"sk_buff"}, Return: ret, Params: params,
+		}
+	}
+	// f_far 的参数距离为 3，超出 depth；f_none 与 f_pp 的 o 与 sk_buff 无关
+	want := []RelatedFunc{
+		fn("f_restrict", "int", RelatedParam{Index: 0, Name: "skb", Type: "const struct sk_buff *restrict", Seed: "sk_buff", Chain: []ChainLink{
+			{Type: "const struct sk_buff *restrict"}, {Type: "const struct sk_buff *"}, {Type: "const struct sk_buff"}, {Type: "struct sk_buff"},
+		}}),
+		fn("f_pp", "void", RelatedParam{Index: 1, Name: "pskb", Type: "struct sk_buff **", Seed: "sk_buff", Chain: append([]ChainLink{{Type: "struct sk_buff **"}}, skb...)}),
+		fn("f_wrap", "struct wrap1 *", RelatedParam{Index: 0, Name: "w", Type: "struct wrap2 *", Seed: "sk_buff", Distance: 2, Chain: append([]ChainLink{
+			{Type: "struct wrap2 *"}, {Type: "struct wrap2", Member: "w"}, {Type: "struct wrap1 *"}, {Type: "struct wrap1", Member: "skb"},
+		}, skb...)}),
+		fn("f_typedef", "int", RelatedParam{Index: 0, Type: "skb_t *", Seed: "sk_buff", Chain: []ChainLink{{Type: "skb_t *"}, {Type: "skb_t"}, {Type: "struct sk_buff"}}}),
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d seed results, want 1", len(results))
+	}
+	got := results[0].Funcs
+	if len(got) != len(want) {
+		t.Fatalf("got %d funcs %v, want %d", len(got), got, len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("func %d = %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+}
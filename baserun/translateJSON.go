@@ -9,45 +9,76 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 )
 
+// TranslateJSON is like the package-level TranslateJSON, but builds the
+// maps from the related functions of o's own BTF, seeds and depth instead
+// of whatever ./.cache/relatedFuncD5.json holds. It reuses FuncIDMap.json
+// and kProberFunc.c from the BTF cache when they were already generated for
+// the same sources, and stores them there otherwise.
+func (o *Options) TranslateJSON() error {
+	src, err := o.readSources()
+	if err != nil {
+		return err
+	}
+	if err := ensureCache(cacheDir); err != nil {
+		return err
+	}
+	if ok, err := src.restore(funcIDMapFile, kProberFile); err != nil || ok {
+		return err
+	}
+	funcs, err := src.relatedFuncs()
+	if err != nil {
+		return err
+	}
+	if err := translate(funcs); err != nil {
+		return err
+	}
+	return src.store(funcIDMapFile, kProberFile)
+}
+
 // TranslateJSON reads ./.cache/relatedFuncD5.json, builds a mapping by id
 // and writes ./.cache/FuncIDMap.json. It also generates
 // ./.cache/kProberFunc.c from templates and the discovered functions.
 // This function is exported; helpers below are unexported.
 func TranslateJSON() error {
-	// ensure cache dir
-	if err := os.MkdirAll("./.cache", 0o755); err != nil {
-		return err
-	}
-
 	inPath := filepath.Join(".", ".cache", "relatedFuncD5.json")
 	b, err := ioutil.ReadFile(inPath)
 	if err != nil {
 		return fmt.Errorf("read %s: %w", inPath, err)
 	}
 
-	var mainFile []map[string]interface{}
+	var mainFile []RelatedFunc
 	if err := json.Unmarshal(b, &mainFile); err != nil {
 		return fmt.Errorf("unmarshal %s: %w", inPath, err)
 	}
+	return translate(mainFile)
+}
+
+// translate writes ./.cache/FuncIDMap.json and ./.cache/kProberFunc.c for mainFile.
+func translate(mainFile []RelatedFunc) error {
+	// ensure cache dir
+	if err := os.MkdirAll("./.cache", 0o755); err != nil {
+		return err
+	}
 
 	subjs := rebuildJSON(mainFile)
 
 	// add the hard-coded entries from the original script
-	subjs["200000"] = map[string]interface{}{"id": 200000, "name": "ip_rcv_core"}
-	subjs["200001"] = map[string]interface{}{"id": 200001, "name": "ip6_rcv_core"}
-	subjs["200002"] = map[string]interface{}{"id": 200002, "name": "icmp_push_reply"}
-	subjs["200003"] = map[string]interface{}{"id": 200003, "name": "rawv6_sendmsg"}
-	subjs["200004"] = map[string]interface{}{"id": 200004, "name": "raw_sendmsg"}
-	subjs["200005"] = map[string]interface{}{"id": 200005, "name": "udp_sendmsg"}
-	subjs["200006"] = map[string]interface{}{"id": 200006, "name": "udpv6_sendmsg"}
-	subjs["200007"] = map[string]interface{}{"id": 200007, "name": "tcp_sendmsg"}
-	subjs["300000"] = map[string]interface{}{"id": 300000, "name": "ip_rcv"}
-	subjs["300001"] = map[string]interface{}{"id": 300001, "name": "ipv6_rcv"}
-	subjs["300002"] = map[string]interface{}{"id": 300002, "name": "ip_list_rcv"}
-	subjs["300003"] = map[string]interface{}{"id": 300003, "name": "ipv6_list_rcv"}
+	subjs["200000"] = RelatedFunc{ID: 200000, Name: "ip_rcv_core"}
+	subjs["200001"] = RelatedFunc{ID: 200001, Name: "ip6_rcv_core"}
+	subjs["200002"] = RelatedFunc{ID: 200002, Name: "icmp_push_reply"}
+	subjs["200003"] = RelatedFunc{ID: 200003, Name: "rawv6_sendmsg"}
+	subjs["200004"] = RelatedFunc{ID: 200004, Name: "raw_sendmsg"}
+	subjs["200005"] = RelatedFunc{ID: 200005, Name: "udp_sendmsg"}
+	subjs["200006"] = RelatedFunc{ID: 200006, Name: "udpv6_sendmsg"}
+	subjs["200007"] = RelatedFunc{ID: 200007, Name: "tcp_sendmsg"}
+	subjs["300000"] = RelatedFunc{ID: 300000, Name: "ip_rcv"}
+	subjs["300001"] = RelatedFunc{ID: 300001, Name: "ipv6_rcv"}
+	subjs["300002"] = RelatedFunc{ID: 300002, Name: "ip_list_rcv"}
+	subjs["300003"] = RelatedFunc{ID: 300003, Name: "ipv6_list_rcv"}
 
 	outPath := filepath.Join(".", ".cache", "FuncIDMap.json")
 	outB, err := json.MarshalIndent(subjs, "", "  ")
@@ -77,7 +108,7 @@ func TranslateJSON() error {
 // helper types and data
 type funcInfo struct {
 	name string
-	id   interface{}
+	id   uint64
 }
 
 var disabledList = []string{"____sys_recvmsg", "___sys_recvmsg", "sock_recvmsg", "security_socket_recvmsg",
@@ -96,33 +127,11 @@ var specList = []string{"ip_rcv_core", "ip6_rcv_core", "icmp_push_reply", "rawv6
 	"raw_sendmsg", "udp_sendmsg", "udpv6_sendmsg", "tcp_sendmsg", "ipv6_rcv", "ip_rcv", "ip_list_rcv", "ipv6_list_rcv",
 }
 
-func rebuildJSON(input []map[string]interface{}) map[string]map[string]interface{} {
-	dictnow := make(map[string]map[string]interface{})
+// rebuildJSON 按 id 建立 FuncIDMap.json 的字典。
+func rebuildJSON(input []RelatedFunc) map[string]RelatedFunc {
+	dictnow := make(map[string]RelatedFunc, len(input))
 	for _, item := range input {
-		var key string
-		if v, ok := item["id"]; ok {
-			switch t := v.(type) {
-			case float64:
-				key = fmt.Sprintf("%d", int64(t))
-			case int:
-				key = fmt.Sprintf("%d", t)
-			case int64:
-				key = fmt.Sprintf("%d", t)
-			case string:
-				key = t
-			default:
-				key = fmt.Sprintf("%v", v)
-			}
-		} else {
-			// fallback: try name
-			if n, ok := item["name"].(string); ok {
-				key = n
-			} else {
-				continue
-			}
-		}
-		// store the original item map
-		dictnow[key] = item
+		dictnow[strconv.FormatUint(item.ID, 10)] = item
 	}
 	return dictnow
 }
@@ -136,12 +145,14 @@ func inList(s string, list []string) bool {
 	return false
 }
 
-func selectFunctions(mainFile []map[string]interface{}) []funcInfo {
+func selectFunctions(mainFile []RelatedFunc) []funcInfo {
 	keywordList := []string{"tcp", "udp", "icmp", "recv", "send", "xmit", "ip", "sk", "sock"}
 	var ret []funcInfo
+	// 模块中可能有与 vmlinux 同名的 static 函数，同名的只生成一次探针
+	seen := make(map[string]bool)
 	for _, item := range mainFile {
-		name, _ := item["name"].(string)
-		if name == "" {
+		name := item.Name
+		if name == "" || seen[name] {
 			continue
 		}
 		if strings.Contains(name, "bpf") || strings.Contains(name, "trace") || inList(name, disabledList) {
@@ -158,7 +169,8 @@ func selectFunctions(mainFile []map[string]interface{}) []funcInfo {
 			}
 		}
 		if found {
-			ret = append(ret, funcInfo{name: name, id: item["id"]})
+			seen[name] = true
+			ret = append(ret, funcInfo{name: name, id: item.ID})
 		}
 	}
 	return ret
@@ -0,0 +1,612 @@
+// Package btf 读取内核 BTF（BPF Type Format）的原始二进制格式，例如 /sys/kernel/btf/vmlinux。
+//
+// 格式见内核文档 Documentation/bpf/btf.rst：一个 24 字节的 btf_header，
+// 之后是 type 段（按 ID 顺序排列的 btf_type 及其附加数据）与 string 段（以 NUL 分隔的名字）。
+package btf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	Magic         = 0xeb9f
+	headerLen     = 24
+	btfTypeLen    = 12
+	VmlinuxPath   = "/sys/kernel/btf/vmlinux"
+	ModuleDir     = "/sys/kernel/btf" // 每个已加载模块一个 split BTF 文件
+	maxVlen       = 0xffff
+	kindFlagShift = 31
+)
+
+// Header 是 struct btf_header。TypeOff、StrOff 相对于 header 末尾（HdrLen）。
+type Header struct {
+	Magic   uint16 `json:"magic"`
+	Version uint8  `json:"version"`
+	Flags   uint8  `json:"flags"`
+	HdrLen  uint32 `json:"hdr_len"`
+	TypeOff uint32 `json:"type_off"`
+	TypeLen uint32 `json:"type_len"`
+	StrOff  uint32 `json:"str_off"`
+	StrLen  uint32 `json:"str_len"`
+}
+
+// TypeID 是类型在 type 段中的序号，0 表示 void。
+type TypeID uint32
+
+// Kind 是 BTF_KIND_*。
+type Kind uint8
+
+const (
+	KindUnknown Kind = iota
+	KindInt
+	KindPtr
+	KindArray
+	KindStruct
+	KindUnion
+	KindEnum
+	KindFwd
+	KindTypedef
+	KindVolatile
+	KindConst
+	KindRestrict
+	KindFunc
+	KindFuncProto
+	KindVar
+	KindDatasec
+	KindFloat
+	KindDeclTag
+	KindTypeTag
+	KindEnum64
+)
+
+// kindNames 与 bpftool -j btf dump 输出的 "kind" 一致。
+var kindNames = [...]string{
+	KindUnknown:   "UNKNOWN",
+	KindInt:       "INT",
+	KindPtr:       "PTR",
+	KindArray:     "ARRAY",
+	KindStruct:    "STRUCT",
+	KindUnion:     "UNION",
+	KindEnum:      "ENUM",
+	KindFwd:       "FWD",
+	KindTypedef:   "TYPEDEF",
+	KindVolatile:  "VOLATILE",
+	KindConst:     "CONST",
+	KindRestrict:  "RESTRICT",
+	KindFunc:      "FUNC",
+	KindFuncProto: "FUNC_PROTO",
+	KindVar:       "VAR",
+	KindDatasec:   "DATASEC",
+	KindFloat:     "FLOAT",
+	KindDeclTag:   "DECL_TAG",
+	KindTypeTag:   "TYPE_TAG",
+	KindEnum64:    "ENUM64",
+}
+
+func (k Kind) String() string {
+	if int(k) < len(kindNames) {
+		return kindNames[k]
+	}
+	return fmt.Sprintf("KIND(%d)", uint8(k))
+}
+
+// ParseKind 按 bpftool 的名字解析 kind，不区分大小写，例如 "struct"、"FUNC_PROTO"。
+func ParseKind(name string) (Kind, error) {
+	for k, n := range kindNames {
+		if strings.EqualFold(n, name) {
+			return Kind(k), nil
+		}
+	}
+	return KindUnknown, fmt.Errorf("btf: unknown kind %q", name)
+}
+
+// rawType 是一条 btf_type 记录及其附加数据（引用仍是类型 ID），各字段只在对应的 kind 下有意义。
+type rawType struct {
+	ID       TypeID
+	Name     string
+	Kind     Kind
+	KindFlag bool
+	Vlen     int
+	// SizeType 对 INT、STRUCT、UNION、ENUM、ENUM64、DATASEC、FLOAT 是字节数，其余 kind 是引用的类型 ID。
+	SizeType uint32
+
+	IntEncoding  uint32      // INT：BTF_INT_ENCODING、BTF_INT_OFFSET、BTF_INT_BITS 打包后的值
+	Array        rawArray    // ARRAY
+	Members      []rawMember // STRUCT、UNION
+	Enums        []rawEnum   // ENUM、ENUM64
+	Params       []rawParam  // FUNC_PROTO
+	Linkage      uint32      // FUNC（来自 vlen）、VAR
+	Secinfos     []rawSecinfo
+	ComponentIdx int32 // DECL_TAG：-1 表示修饰类型本身，否则为成员/参数序号
+}
+
+type rawArray struct {
+	Type      TypeID
+	IndexType TypeID
+	Nelems    uint32
+}
+
+// rawMember 是 btf_member。KindFlag 为 1 时 Offset 高 8 位是位域宽度，低 24 位是位偏移。
+type rawMember struct {
+	Name   string
+	Type   TypeID
+	Offset uint32
+}
+
+type rawEnum struct {
+	Name  string
+	Value int64
+}
+
+type rawParam struct {
+	Name string
+	Type TypeID
+}
+
+type rawSecinfo struct {
+	Type   TypeID
+	Offset uint32
+	Size   uint32
+}
+
+// Spec 是解析后的一个 BTF 数据块。Types 按 ID 排列：对 vmlinux 等独立的 BTF，Types[0] 为 *Void；
+// 对模块的 split BTF，Types 只包含模块自己的类型，Types[i] 的 ID 为 FirstID+i，
+// 更小的 ID 以及相应的名字由 Base 解析。
+type Spec struct {
+	Header  Header
+	Types   []Type
+	Module  string // split BTF 所属的模块名，vmlinux 为空
+	FirstID TypeID
+	Base    *Spec
+
+	byName  map[string][]Type
+	strings []byte
+	strBase uint32 // 本块字符串偏移的起点，即 Base 的字符串总长度
+	order   binary.ByteOrder
+}
+
+// LoadFile 读取并解析 path 处的原始 BTF 数据。
+func LoadFile(path string) (*Spec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// LoadKernel 解析当前内核的 /sys/kernel/btf/vmlinux（需要 CONFIG_DEBUG_INFO_BTF）。
+func LoadKernel() (*Spec, error) {
+	return LoadFile(VmlinuxPath)
+}
+
+// LoadSplitFile 读取 path 处的 split BTF（例如 /sys/kernel/btf/nf_conntrack），引用按 base 解析。
+func LoadSplitFile(path string, base *Spec) (*Spec, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := ParseSplit(b, base)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	spec.Module = filepath.Base(path)
+	return spec, nil
+}
+
+// LoadModules 解析 dir（通常为 ModuleDir）中除 vmlinux 以外的全部模块 BTF，按模块名排序。
+func LoadModules(dir string, base *Spec) ([]*Spec, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var mods []*Spec
+	for _, e := range entries {
+		if e.Name() == "vmlinux" || e.IsDir() {
+			continue
+		}
+		spec, err := LoadSplitFile(filepath.Join(dir, e.Name()), base)
+		if err != nil {
+			return nil, err
+		}
+		mods = append(mods, spec)
+	}
+	return mods, nil
+}
+
+// Parse 解析原始 BTF 数据。字节序由 magic 判断，因此也能读取其他架构的 BTF。
+func Parse(b []byte) (*Spec, error) {
+	return ParseSplit(b, nil)
+}
+
+// ParseSplit 解析 split BTF：类型 ID 从 base 的最后一个 ID 之后开始，
+// 字符串偏移从 base 的字符串段之后开始。base 为 nil 时等同于 Parse。
+func ParseSplit(b []byte, base *Spec) (*Spec, error) {
+	if len(b) < headerLen {
+		return nil, errors.New("btf: data too short for header")
+	}
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint16(b) == Magic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint16(b) == Magic:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("btf: bad magic %#04x", binary.LittleEndian.Uint16(b))
+	}
+	h := Header{
+		Magic:   Magic,
+		Version: b[2],
+		Flags:   b[3],
+		HdrLen:  order.Uint32(b[4:8]),
+		TypeOff: order.Uint32(b[8:12]),
+		TypeLen: order.Uint32(b[12:16]),
+		StrOff:  order.Uint32(b[16:20]),
+		StrLen:  order.Uint32(b[20:24]),
+	}
+	if h.Version != 1 {
+		return nil, fmt.Errorf("btf: unsupported version %d", h.Version)
+	}
+	typeSec, err := section(b, h.HdrLen, h.TypeOff, h.TypeLen, "type")
+	if err != nil {
+		return nil, err
+	}
+	strSec, err := section(b, h.HdrLen, h.StrOff, h.StrLen, "string")
+	if err != nil {
+		return nil, err
+	}
+	if base == nil && len(strSec) > 0 && strSec[0] != 0 {
+		return nil, errors.New("btf: string section does not start with NUL")
+	}
+
+	s := &Spec{Header: h, Base: base, strings: strSec, order: order}
+	if base != nil {
+		if base.order != order {
+			return nil, errors.New("btf: split BTF byte order differs from base")
+		}
+		s.FirstID = base.nextID()
+		s.strBase = base.strEnd()
+	}
+	raws, err := s.parseTypes(typeSec)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.buildTypes(raws); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func section(b []byte, hdrLen, off, n uint32, name string) ([]byte, error) {
+	start := uint64(hdrLen) + uint64(off)
+	end := start + uint64(n)
+	if end > uint64(len(b)) {
+		return nil, fmt.Errorf("btf: %s section [%d, %d) out of range (%d bytes)", name, start, end, len(b))
+	}
+	return b[start:end], nil
+}
+
+func (s *Spec) nextID() TypeID { return s.FirstID + TypeID(len(s.Types)) }
+func (s *Spec) strEnd() uint32 { return s.strBase + uint32(len(s.strings)) }
+
+// String 返回 string 段中 off 处的名字。
+func (s *Spec) String(off uint32) (string, error) {
+	if off < s.strBase {
+		return s.Base.String(off)
+	}
+	off -= s.strBase
+	if int(off) >= len(s.strings) {
+		return "", fmt.Errorf("btf: string offset %d out of range", off+s.strBase)
+	}
+	str := s.strings[off:]
+	if i := bytes.IndexByte(str, 0); i >= 0 {
+		str = str[:i]
+	}
+	return string(str), nil
+}
+
+func (s *Spec) parseTypes(b []byte) ([]rawType, error) {
+	var raws []rawType
+	if s.Base == nil {
+		raws = append(raws, rawType{ID: 0, Kind: KindUnknown}) // void
+	}
+	u32 := func(off int) uint32 { return s.order.Uint32(b[off : off+4]) }
+	name := func(off uint32) (string, error) { return s.String(off) }
+
+	for off := 0; off < len(b); {
+		if off+btfTypeLen > len(b) {
+			return nil, fmt.Errorf("btf: truncated type at offset %d", off)
+		}
+		info := u32(off + 4)
+		t := rawType{
+			ID:       s.FirstID + TypeID(len(raws)),
+			Kind:     Kind(info >> 24 & 0x1f),
+			KindFlag: info>>kindFlagShift == 1,
+			Vlen:     int(info & maxVlen),
+			SizeType: u32(off + 8),
+		}
+		var err error
+		if t.Name, err = name(u32(off)); err != nil {
+			return nil, err
+		}
+		off += btfTypeLen
+
+		extra := 0
+		switch t.Kind {
+		case KindInt:
+			extra = 4
+		case KindArray:
+			extra = 12
+		case KindStruct, KindUnion:
+			extra = t.Vlen * 12
+		case KindEnum:
+			extra = t.Vlen * 8
+		case KindFuncProto:
+			extra = t.Vlen * 8
+		case KindVar:
+			extra = 4
+		case KindDatasec:
+			extra = t.Vlen * 12
+		case KindDeclTag:
+			extra = 4
+		case KindEnum64:
+			extra = t.Vlen * 12
+		case KindPtr, KindFwd, KindTypedef, KindVolatile, KindConst, KindRestrict,
+			KindFunc, KindFloat, KindTypeTag:
+		default:
+			return nil, fmt.Errorf("btf: type %d has unknown kind %d", t.ID, t.Kind)
+		}
+		if off+extra > len(b) {
+			return nil, fmt.Errorf("btf: truncated %s %d", t.Kind, t.ID)
+		}
+
+		switch t.Kind {
+		case KindInt:
+			t.IntEncoding = u32(off)
+		case KindArray:
+			t.Array = rawArray{Type: TypeID(u32(off)), IndexType: TypeID(u32(off + 4)), Nelems: u32(off + 8)}
+		case KindStruct, KindUnion:
+			t.Members = make([]rawMember, t.Vlen)
+			for i := range t.Members {
+				p := off + i*12
+				if t.Members[i].Name, err = name(u32(p)); err != nil {
+					return nil, err
+				}
+				t.Members[i].Type = TypeID(u32(p + 4))
+				t.Members[i].Offset = u32(p + 8)
+			}
+		case KindEnum:
+			t.Enums = make([]rawEnum, t.Vlen)
+			for i := range t.Enums {
+				p := off + i*8
+				if t.Enums[i].Name, err = name(u32(p)); err != nil {
+					return nil, err
+				}
+				// kind_flag 为 1 表示有符号：符号扩展，否则零扩展
+				if t.KindFlag {
+					t.Enums[i].Value = int64(int32(u32(p + 4)))
+				} else {
+					t.Enums[i].Value = int64(u32(p + 4))
+				}
+			}
+		case KindEnum64:
+			t.Enums = make([]rawEnum, t.Vlen)
+			for i := range t.Enums {
+				p := off + i*12
+				if t.Enums[i].Name, err = name(u32(p)); err != nil {
+					return nil, err
+				}
+				t.Enums[i].Value = int64(uint64(u32(p+8))<<32 | uint64(u32(p+4)))
+			}
+		case KindFuncProto:
+			t.Params = make([]rawParam, t.Vlen)
+			for i := range t.Params {
+				p := off + i*8
+				if t.Params[i].Name, err = name(u32(p)); err != nil {
+					return nil, err
+				}
+				t.Params[i].Type = TypeID(u32(p + 4))
+			}
+		case KindFunc:
+			t.Linkage = uint32(t.Vlen)
+		case KindVar:
+			t.Linkage = u32(off)
+		case KindDatasec:
+			t.Secinfos = make([]rawSecinfo, t.Vlen)
+			for i := range t.Secinfos {
+				p := off + i*12
+				t.Secinfos[i] = rawSecinfo{Type: TypeID(u32(p)), Offset: u32(p + 4), Size: u32(p + 8)}
+			}
+		case KindDeclTag:
+			t.ComponentIdx = int32(u32(off))
+		}
+		off += extra
+		raws = append(raws, t)
+	}
+	return raws, nil
+}
+
+// buildTypes 把 rawType 转换为具体类型：先为每个 ID 分配对象，再解析相互引用（引用可以指向后面的 ID）。
+func (s *Spec) buildTypes(raws []rawType) error {
+	s.Types = make([]Type, len(raws))
+	for i := range raws {
+		r := &raws[i]
+		id := typeID{r.ID}
+		var t Type
+		switch r.Kind {
+		case KindUnknown:
+			t = &Void{}
+		case KindInt:
+			t = &Int{
+				typeID:   id,
+				Name:     r.Name,
+				Size:     r.SizeType,
+				Encoding: IntEncoding(r.IntEncoding >> 24 & 0x0f),
+				Offset:   r.IntEncoding >> 16 & 0xff,
+				Bits:     r.IntEncoding & 0xff,
+			}
+		case KindFloat:
+			t = &Float{typeID: id, Name: r.Name, Size: r.SizeType}
+		case KindPtr:
+			t = &Ptr{typeID: id}
+		case KindArray:
+			t = &Array{typeID: id, Nelems: r.Array.Nelems}
+		case KindStruct:
+			t = &Struct{typeID: id, Name: r.Name, Size: r.SizeType, Members: make([]Member, len(r.Members))}
+		case KindUnion:
+			t = &Union{typeID: id, Name: r.Name, Size: r.SizeType, Members: make([]Member, len(r.Members))}
+		case KindEnum:
+			t = &Enum{typeID: id, Name: r.Name, Size: r.SizeType, Signed: r.KindFlag, Values: enumValues(r.Enums)}
+		case KindEnum64:
+			t = &Enum64{typeID: id, Name: r.Name, Size: r.SizeType, Signed: r.KindFlag, Values: enumValues(r.Enums)}
+		case KindFwd:
+			t = &Fwd{typeID: id, Name: r.Name, Union: r.KindFlag}
+		case KindTypedef:
+			t = &Typedef{typeID: id, Name: r.Name}
+		case KindVolatile:
+			t = &Volatile{typeID: id}
+		case KindConst:
+			t = &Const{typeID: id}
+		case KindRestrict:
+			t = &Restrict{typeID: id}
+		case KindFunc:
+			t = &Func{typeID: id, Name: r.Name, Linkage: Linkage(r.Linkage)}
+		case KindFuncProto:
+			t = &FuncProto{typeID: id, Params: make([]FuncParam, len(r.Params))}
+		case KindVar:
+			t = &Var{typeID: id, Name: r.Name, Linkage: Linkage(r.Linkage)}
+		case KindDatasec:
+			t = &Datasec{typeID: id, Name: r.Name, Size: r.SizeType, Vars: make([]VarSecinfo, len(r.Secinfos))}
+		case KindDeclTag:
+			t = &DeclTag{typeID: id, Value: r.Name, Index: int(r.ComponentIdx)}
+		case KindTypeTag:
+			t = &TypeTag{typeID: id, Value: r.Name}
+		}
+		s.Types[i] = t
+	}
+
+	for i := range raws {
+		r := &raws[i]
+		var err error
+		ref := func(id TypeID) Type {
+			if err != nil {
+				return nil
+			}
+			var t Type
+			t, err = s.TypeByID(id)
+			if err != nil {
+				err = fmt.Errorf("btf: %s %d refers to %w", r.Kind, r.ID, err)
+			}
+			return t
+		}
+		switch t := s.Types[i].(type) {
+		case *Ptr:
+			t.Target = ref(TypeID(r.SizeType))
+		case *Array:
+			t.Type = ref(r.Array.Type)
+			t.Index = ref(r.Array.IndexType)
+		case *Struct:
+			fillMembers(t.Members, r, ref)
+		case *Union:
+			fillMembers(t.Members, r, ref)
+		case *Typedef:
+			t.Type = ref(TypeID(r.SizeType))
+		case *Volatile:
+			t.Type = ref(TypeID(r.SizeType))
+		case *Const:
+			t.Type = ref(TypeID(r.SizeType))
+		case *Restrict:
+			t.Type = ref(TypeID(r.SizeType))
+		case *TypeTag:
+			t.Type = ref(TypeID(r.SizeType))
+		case *Func:
+			proto, ok := ref(TypeID(r.SizeType)).(*FuncProto)
+			if !ok && err == nil {
+				err = fmt.Errorf("btf: FUNC %d (%s) type %d is not a FUNC_PROTO", r.ID, r.Name, r.SizeType)
+			}
+			t.Type = proto
+		case *FuncProto:
+			t.Return = ref(TypeID(r.SizeType))
+			for j, p := range r.Params {
+				t.Params[j] = FuncParam{Name: p.Name, Type: ref(p.Type)}
+			}
+		case *Var:
+			t.Type = ref(TypeID(r.SizeType))
+		case *Datasec:
+			for j, v := range r.Secinfos {
+				t.Vars[j] = VarSecinfo{Type: ref(v.Type), Offset: v.Offset, Size: v.Size}
+			}
+		case *DeclTag:
+			t.Type = ref(TypeID(r.SizeType))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	s.byName = make(map[string][]Type)
+	for _, t := range s.Types {
+		if name := t.TypeName(); name != "" {
+			s.byName[name] = append(s.byName[name], t)
+		}
+	}
+	return nil
+}
+
+func fillMembers(ms []Member, r *rawType, ref func(TypeID) Type) {
+	for j, m := range r.Members {
+		ms[j] = Member{Name: m.Name, Type: ref(m.Type), Offset: m.Offset}
+		if r.KindFlag {
+			ms[j].BitfieldSize = m.Offset >> 24
+			ms[j].Offset = m.Offset & 0xffffff
+		}
+	}
+}
+
+func enumValues(raws []rawEnum) []EnumValue {
+	vals := make([]EnumValue, len(raws))
+	for i, e := range raws {
+		vals[i] = EnumValue{Name: e.Name, Value: e.Value}
+	}
+	return vals
+}
+
+// TypeByID 返回 id 对应的类型，split BTF 中小于 FirstID 的 ID 由 Base 解析。
+func (s *Spec) TypeByID(id TypeID) (Type, error) {
+	if id < s.FirstID {
+		return s.Base.TypeByID(id)
+	}
+	if int(id-s.FirstID) >= len(s.Types) {
+		return nil, fmt.Errorf("btf: type id %d out of range", id)
+	}
+	return s.Types[id-s.FirstID], nil
+}
+
+// TypesByName 返回名为 name 的全部类型（不同 kind 可以同名，例如 struct 与同名 FUNC），
+// split BTF 先返回 Base 中的类型。DECL_TAG、TYPE_TAG 按标签值索引。
+func (s *Spec) TypesByName(name string) []Type {
+	if s.Base == nil {
+		return s.byName[name]
+	}
+	base := s.Base.TypesByName(name)
+	return append(base[:len(base):len(base)], s.byName[name]...)
+}
+
+// TypeByName 返回名为 name、kind 为 kind 的第一个类型。
+func (s *Spec) TypeByName(name string, kind Kind) (Type, error) {
+	for _, t := range s.TypesByName(name) {
+		if t.Kind() == kind {
+			return t, nil
+		}
+	}
+	return nil, fmt.Errorf("btf: %s %q not found", kind, name)
+}
@@ -0,0 +1,252 @@
+package btf
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Field 是成员路径解析的结果。偏移都相对于路径的根类型。
+//
+// 位域按 libbpf 的 bpf_core_read_bitfield 的方式描述：从 Offset 处读取 Size 字节的整数（小端），
+// 右移 BitShift 位后取低 BitfieldSize 位。
+type Field struct {
+	Path         string `json:"path"`
+	Type         Type   `json:"-"`      // 最终成员的类型（保留 typedef，例如 __be32）
+	Offset       uint32 `json:"offset"` // 字节偏移；位域为包含它的存储单元的偏移
+	Size         uint32 `json:"size"`   // 字节大小；位域为存储单元的大小
+	BitOffset    uint32 `json:"bit_offset"`
+	BitfieldSize uint32 `json:"bitfield_size,omitempty"` // 位域宽度，0 表示不是位域
+	BitShift     uint32 `json:"bit_shift,omitempty"`     // 位域在存储单元中的起始位
+}
+
+// MarshalJSON 把 Type 输出为 C 类型名。
+func (f *Field) MarshalJSON() ([]byte, error) {
+	type field Field
+	return json.Marshal(struct {
+		*field
+		Type string `json:"type"`
+	}{(*field)(f), CType(f.Type)})
+}
+
+// Field 解析 "sock.__sk_common.skc_daddr" 这样的路径：第一段是 struct、union 或 typedef 的名字
+// （可带 "struct " 前缀），其余各段是成员名，可带数组下标（"sk_buff.cb[4]"）。
+// 匿名 struct/union 中的成员可以直接引用，和 C 一样。路径不能经过指针。
+func (s *Spec) Field(path string) (*Field, error) {
+	root, rest, _ := strings.Cut(strings.TrimSpace(path), ".")
+	for _, prefix := range []string{"struct ", "union "} {
+		root = strings.TrimSpace(strings.TrimPrefix(root, prefix))
+	}
+	var t Type
+	for _, kind := range []Kind{KindStruct, KindUnion, KindTypedef} {
+		if found, err := s.TypeByName(root, kind); err == nil {
+			t = found
+			break
+		}
+	}
+	if t == nil {
+		return nil, fmt.Errorf("btf: struct, union or typedef %q not found", root)
+	}
+	f, err := FieldOf(t, rest)
+	if err != nil {
+		return nil, fmt.Errorf("btf: %s: %w", path, err)
+	}
+	f.Path = root
+	if rest != "" {
+		f.Path += "." + rest
+	}
+	return f, nil
+}
+
+// FieldOf 解析 t 中相对于 t 的成员路径 path（不含根类型名，格式同 Spec.Field）。
+// path 为空时返回 t 本身。
+func FieldOf(t Type, path string) (*Field, error) {
+	size, err := Sizeof(t)
+	if err != nil {
+		return nil, err
+	}
+	f := &Field{Path: path, Type: t, Size: size}
+	if path == "" {
+		return f, nil
+	}
+	var bitOff uint32
+	for _, part := range strings.Split(path, ".") {
+		name, indices, err := splitIndices(part)
+		if err != nil {
+			return nil, err
+		}
+		members := compositeMembers(t)
+		if members == nil {
+			if _, ok := skipQualifiers(resolveTypedef(t)).(*Ptr); ok {
+				return nil, fmt.Errorf("%q: cannot follow pointer %s", name, CType(t))
+			}
+			return nil, fmt.Errorf("%q: %s is not a struct or union", name, CType(t))
+		}
+		m, off, ok := findMember(members, name)
+		if !ok {
+			return nil, fmt.Errorf("%s has no member %q", CType(t), name)
+		}
+		if f.BitfieldSize > 0 {
+			return nil, fmt.Errorf("%q: bitfield has no members", name)
+		}
+		bitOff += off
+		t = m.Type
+		f.BitfieldSize = m.BitfieldSize
+		if f.BitfieldSize == 0 {
+			// 没有 kind_flag 的 struct 把位域编码在 INT 中
+			if i, ok := skipQualifiers(resolveTypedef(t)).(*Int); ok && (i.Offset != 0 || i.Bits != i.Size*8) {
+				bitOff += i.Offset
+				f.BitfieldSize = i.Bits
+			}
+		}
+		for _, idx := range indices {
+			arr, ok := skipQualifiers(resolveTypedef(t)).(*Array)
+			if !ok {
+				return nil, fmt.Errorf("%q: %s is not an array", part, CType(t))
+			}
+			if idx >= arr.Nelems && arr.Nelems > 0 {
+				return nil, fmt.Errorf("%q: index %d out of range [0,%d)", part, idx, arr.Nelems)
+			}
+			elemSize, err := Sizeof(arr.Type)
+			if err != nil {
+				return nil, err
+			}
+			bitOff += idx * elemSize * 8
+			t = arr.Type
+		}
+	}
+
+	f.Type = t
+	f.BitOffset = bitOff
+	if f.Size, err = Sizeof(t); err != nil {
+		return nil, err
+	}
+	if f.BitfieldSize == 0 {
+		if bitOff%8 != 0 {
+			return nil, fmt.Errorf("%s: bit offset %d is not byte aligned", path, bitOff)
+		}
+		f.Offset = bitOff / 8
+		return f, nil
+	}
+	if f.Size == 0 {
+		return nil, fmt.Errorf("%s: bitfield of zero-size type %s", path, CType(t))
+	}
+	// 找到能容纳整个位域的、按自身大小对齐的存储单元
+	for f.Size <= 8 {
+		f.Offset = bitOff / 8 / f.Size * f.Size
+		if bitOff+f.BitfieldSize <= (f.Offset+f.Size)*8 {
+			f.BitShift = bitOff - f.Offset*8
+			return f, nil
+		}
+		f.Size *= 2
+	}
+	return nil, fmt.Errorf("%s: bitfield at bit %d does not fit in 8 bytes", path, bitOff)
+}
+
+// findMember 在 members 中查找 name，并递归查找匿名 struct/union 的成员。
+// 返回的偏移（位）相对于 members 所属的类型。
+func findMember(members []Member, name string) (*Member, uint32, bool) {
+	for i := range members {
+		if members[i].Name == name {
+			return &members[i], members[i].Offset, true
+		}
+	}
+	for i := range members {
+		if members[i].Name != "" {
+			continue
+		}
+		if sub := compositeMembers(members[i].Type); sub != nil {
+			if m, off, ok := findMember(sub, name); ok {
+				return m, members[i].Offset + off, true
+			}
+		}
+	}
+	return nil, 0, false
+}
+
+// compositeMembers 返回 t（跳过 typedef 与修饰符）作为 struct/union 的成员，否则返回 nil。
+func compositeMembers(t Type) []Member {
+	switch v := skipQualifiers(resolveTypedef(t)).(type) {
+	case *Struct:
+		return v.Members
+	case *Union:
+		return v.Members
+	}
+	return nil
+}
+
+// splitIndices 把 "cb[4]" 拆成 "cb" 与 [4]。
+func splitIndices(part string) (string, []uint32, error) {
+	name, rest, ok := strings.Cut(part, "[")
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil, fmt.Errorf("empty member name in %q", part)
+	}
+	if !ok {
+		return name, nil, nil
+	}
+	var indices []uint32
+	for _, s := range strings.Split("["+rest, "[")[1:] {
+		s, ok := strings.CutSuffix(strings.TrimSpace(s), "]")
+		if !ok {
+			return "", nil, fmt.Errorf("invalid index in %q", part)
+		}
+		n, err := strconv.ParseUint(s, 0, 32)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid index in %q", part)
+		}
+		indices = append(indices, uint32(n))
+	}
+	return name, indices, nil
+}
+
+// resolveTypedef 跳过 typedef、修饰符与 TYPE_TAG。
+func resolveTypedef(t Type) Type {
+	for {
+		switch v := skipQualifiers(t).(type) {
+		case *Typedef:
+			t = v.Type
+		default:
+			return v
+		}
+	}
+}
+
+// pointerSize 是 PTR 的大小。BTF 不记录指针大小，这里按 64 位内核处理。
+const pointerSize = 8
+
+// Sizeof 返回 t 的字节大小。VOID、FWD、FUNC、FUNC_PROTO 没有大小。
+func Sizeof(t Type) (uint32, error) {
+	n := uint32(1)
+	for {
+		switch v := t.(type) {
+		case *Int:
+			return n * v.Size, nil
+		case *Float:
+			return n * v.Size, nil
+		case *Enum:
+			return n * v.Size, nil
+		case *Enum64:
+			return n * v.Size, nil
+		case *Struct:
+			return n * v.Size, nil
+		case *Union:
+			return n * v.Size, nil
+		case *Datasec:
+			return n * v.Size, nil
+		case *Ptr:
+			return n * pointerSize, nil
+		case *Array:
+			n *= v.Nelems
+			t = v.Type
+		case *Typedef, *Const, *Volatile, *Restrict, *TypeTag, *Var:
+			t = Target(v)
+		case nil:
+			return 0, errors.New("btf: sizeof nil type")
+		default:
+			return 0, fmt.Errorf("btf: %s has no size", CType(t))
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package btf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CType 返回 t 的 C 类型名，例如 "const struct sk_buff *"、"int (*)(struct sock *, int)"。
+// TYPE_TAG 不输出。
+func CType(t Type) string {
+	return CDecl(t, "")
+}
+
+// CDecl 返回以 name 为名字、类型为 t 的 C 声明，例如 CDecl(t, "skb") = "struct sk_buff *skb"，
+// 函数为 "int ip_rcv(struct sk_buff *skb, ...)"。name 为空时等价于 CType。
+func CDecl(t Type, name string) string {
+	return strings.TrimSpace(declare(t, name))
+}
+
+// declare 按 C 声明符的规则从外向内展开：inner 是已经生成的声明符（名字、*、[]、参数表）。
+func declare(t Type, inner string) string {
+	switch v := t.(type) {
+	case nil:
+		return join("void", inner)
+	case *Ptr:
+		inner = "*" + inner
+		switch skipQualifiers(v.Target).(type) {
+		case *Array, *FuncProto:
+			inner = "(" + inner + ")"
+		}
+		return declare(v.Target, inner)
+	case *Const:
+		return qualify("const", v.Type, inner)
+	case *Volatile:
+		return qualify("volatile", v.Type, inner)
+	case *Restrict:
+		return qualify("restrict", v.Type, inner)
+	case *TypeTag:
+		return declare(v.Type, inner)
+	case *Array:
+		return declare(v.Type, fmt.Sprintf("%s[%d]", inner, v.Nelems))
+	case *FuncProto:
+		return declare(v.Return, inner+"("+params(v)+")")
+	case *Func:
+		if v.Type == nil {
+			return join("void", v.Name+"()")
+		}
+		return declare(v.Type, v.Name)
+	case *Var:
+		return declare(v.Type, inner)
+	}
+	return join(baseName(t), inner)
+}
+
+// qualify 把限定符放在基本类型之前（const char *），或指针的 * 之后（char *const）。
+func qualify(qual string, target Type, inner string) string {
+	if _, ok := skipTags(target).(*Ptr); ok {
+		return declare(target, join(qual, inner))
+	}
+	return qual + " " + declare(target, inner)
+}
+
+func params(fp *FuncProto) string {
+	if len(fp.Params) == 0 {
+		return "void"
+	}
+	out := make([]string, len(fp.Params))
+	for i, p := range fp.Params {
+		if _, ok := p.Type.(*Void); ok && i == len(fp.Params)-1 {
+			out[i] = "..."
+			continue
+		}
+		out[i] = CDecl(p.Type, p.Name)
+	}
+	return strings.Join(out, ", ")
+}
+
+// baseName 返回不含声明符的类型名。匿名 struct/union/enum 写作 "struct {...}"。
+func baseName(t Type) string {
+	var prefix, name string
+	switch v := t.(type) {
+	case *Void:
+		return "void"
+	case *Struct:
+		prefix, name = "struct ", v.Name
+	case *Union:
+		prefix, name = "union ", v.Name
+	case *Enum:
+		prefix, name = "enum ", v.Name
+	case *Enum64:
+		prefix, name = "enum ", v.Name
+	case *Fwd:
+		prefix, name = "struct ", v.Name
+		if v.Union {
+			prefix = "union "
+		}
+	default:
+		return t.TypeName()
+	}
+	if name == "" {
+		name = "{...}"
+	}
+	return prefix + name
+}
+
+func skipQualifiers(t Type) Type {
+	for {
+		switch v := t.(type) {
+		case *Const:
+			t = v.Type
+		case *Volatile:
+			t = v.Type
+		case *Restrict:
+			t = v.Type
+		case *TypeTag:
+			t = v.Type
+		default:
+			return t
+		}
+	}
+}
+
+func skipTags(t Type) Type {
+	for {
+		tag, ok := t.(*TypeTag)
+		if !ok {
+			return t
+		}
+		t = tag.Type
+	}
+}
+
+func join(base, inner string) string {
+	if inner == "" {
+		return base
+	}
+	if strings.HasPrefix(inner, "[") {
+		return base + inner
+	}
+	return base + " " + inner
+}
@@ -0,0 +1,146 @@
+package btf
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// LoadSpec 解析 path 处的 BTF，自动识别以下格式：
+//
+//	原始 BTF（/sys/kernel/btf/vmlinux、pahole --btf_encode_detached 的输出）
+//	带 .BTF 节的 ELF（vmlinux、.ko、BPF 目标文件）
+//	包含以上文件的 tar 包：.tar、.tar.gz/.tgz、.tar.xz/.txz（BTFHub 的 <release>.btf.tar.xz）
+//
+// .tar.xz 需要 PATH 中有 xz。
+func LoadSpec(path string) (*Spec, error) {
+	b, err := ReadBlob(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// ReadBlob 按 LoadSpec 的规则读取 path，返回其中原始 BTF 的字节（不解析类型）。
+func ReadBlob(path string) ([]byte, error) {
+	var (
+		b   []byte
+		err error
+	)
+	switch {
+	case hasSuffix(path, ".tar.xz", ".txz"):
+		// 标准库没有 xz 解码器，交给 xz 命令
+		if _, err := exec.LookPath("xz"); err != nil {
+			return nil, fmt.Errorf("%s: xz not found in PATH, install xz or unpack the archive first", path)
+		}
+		b, err = exec.Command("xz", "-dc", "--", path).Output()
+		if err != nil {
+			return nil, fmt.Errorf("xz -dc %s: %w", path, execError(err))
+		}
+		return blobFromTar(path, bytes.NewReader(b))
+	case hasSuffix(path, ".tar.gz", ".tgz"):
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return blobFromTar(path, zr)
+	case hasSuffix(path, ".tar"):
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return blobFromTar(path, f)
+	}
+	if b, err = os.ReadFile(path); err != nil {
+		return nil, err
+	}
+	raw, err := extractBlob(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return raw, nil
+}
+
+// extractBlob 返回 b 本身（原始 BTF）或 ELF 中 .BTF 节的内容。
+func extractBlob(b []byte) ([]byte, error) {
+	if len(b) >= 2 && (binary.LittleEndian.Uint16(b) == Magic || binary.BigEndian.Uint16(b) == Magic) {
+		return b, nil
+	}
+	if !bytes.HasPrefix(b, []byte(elf.ELFMAG)) {
+		return nil, errors.New("btf: not a raw BTF blob or ELF file")
+	}
+	f, err := elf.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, fmt.Errorf("btf: %w", err)
+	}
+	defer f.Close()
+	sec := f.Section(".BTF")
+	if sec == nil {
+		return nil, errors.New("btf: ELF file has no .BTF section")
+	}
+	data, err := sec.Data()
+	if err != nil {
+		return nil, fmt.Errorf("btf: read .BTF section: %w", err)
+	}
+	return data, nil
+}
+
+// blobFromTar 返回 tar 包中第一个能识别为 BTF 的普通文件。
+func blobFromTar(path string, r io.Reader) ([]byte, error) {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%s: no BTF file in archive", path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		b, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s: %w", path, hdr.Name, err)
+		}
+		if raw, err := extractBlob(b); err == nil {
+			return raw, nil
+		}
+	}
+}
+
+func hasSuffix(path string, suffixes ...string) bool {
+	for _, s := range suffixes {
+		if strings.HasSuffix(path, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// execError 把子进程的 stderr 附加到错误信息中。
+func execError(err error) error {
+	var ee *exec.ExitError
+	if errors.As(err, &ee) && len(ee.Stderr) > 0 {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(ee.Stderr)))
+	}
+	return err
+}
@@ -0,0 +1,147 @@
+package btf
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/Yinzhongkan399/GoServerPS/internal/btftest"
+)
+
+//go:generate go run testdata/gen.go
+
+// minimalFiles 是同一份 BTF（见 testdata/gen.go）的各种封装。
+var minimalFiles = []string{
+	"testdata/minimal.btf",
+	"testdata/minimal.o",
+	"testdata/minimal.btf.tar.gz",
+	"testdata/minimal.btf.tar.xz",
+}
+
+func loadMinimal(t *testing.T, path string) *Spec {
+	t.Helper()
+	if hasSuffix(path, ".tar.xz") {
+		if _, err := exec.LookPath("xz"); err != nil {
+			t.Skip("xz not in PATH")
+		}
+	}
+	spec, err := LoadSpec(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestLoadSpec(t *testing.T) {
+	for _, path := range minimalFiles {
+		t.Run(path, func(t *testing.T) {
+			spec := loadMinimal(t, path)
+			if len(spec.Types) != 12 {
+				t.Fatalf("got %d types, want 12 (void + 11)", len(spec.Types))
+			}
+			fn, err := spec.TypeByName("f", KindFunc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := CDecl(fn, ""), "int f(struct bits *p, enum sgn s)"; got != want {
+				t.Errorf("CDecl(f) = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadSpecErrors(t *testing.T) {
+	for _, path := range []string{"testdata/gen.go", "testdata/missing.btf"} {
+		if _, err := LoadSpec(path); err == nil {
+			t.Errorf("LoadSpec(%q) succeeded, want error", path)
+		}
+	}
+
+	t.Setenv("PATH", "")
+	_, err := LoadSpec("testdata/minimal.btf.tar.xz")
+	if err == nil || !strings.Contains(err.Error(), "xz not found in PATH") {
+		t.Errorf("LoadSpec(.tar.xz) without xz: error = %v, want xz not found in PATH", err)
+	}
+}
+
+func TestEnumSign(t *testing.T) {
+	spec := loadMinimal(t, "testdata/minimal.btf")
+	tests := []struct {
+		name   string
+		kind   Kind
+		signed bool
+		values []EnumValue
+	}{
+		{"sgn", KindEnum, true, []EnumValue{{"S_NEG", -1}, {"S_POS", 1}}},
+		{"usg", KindEnum, false, []EnumValue{{"U_MAX", 0xffffffff}}},
+		{"sgn64", KindEnum64, true, []EnumValue{{"N64", -2}}},
+		// 无符号 ENUM64 的值按位保存在 int64 中
+		{"usg64", KindEnum64, false, []EnumValue{{"BIG", -2}}},
+	}
+	for _, tt := range tests {
+		typ, err := spec.TypeByName(tt.name, tt.kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var signed bool
+		var values []EnumValue
+		switch e := typ.(type) {
+		case *Enum:
+			signed, values = e.Signed, e.Values
+		case *Enum64:
+			signed, values = e.Signed, e.Values
+		}
+		if signed != tt.signed {
+			t.Errorf("%s %s: Signed = %v, want %v", tt.kind, tt.name, signed, tt.signed)
+		}
+		if len(values) != len(tt.values) {
+			t.Errorf("%s %s: values = %v, want %v", tt.kind, tt.name, values, tt.values)
+			continue
+		}
+		for i := range values {
+			if values[i] != tt.values[i] {
+				t.Errorf("%s %s: value %d = %v, want %v", tt.kind, tt.name, i, values[i], tt.values[i])
+			}
+		}
+	}
+}
+
+func TestFieldBitfields(t *testing.T) {
+	spec := loadMinimal(t, "testdata/minimal.btf")
+	tests := []struct {
+		path                                     string
+		offset, size, bitfieldSize, bitShift, bo uint32
+	}{
+		{"bits.a", 0, 4, 3, 0, 0},
+		{"bits.b", 0, 4, 5, 3, 3},
+		{"bits.c", 4, 4, 0, 0, 32},
+		{"bits_t.c", 4, 4, 0, 0, 32},
+	}
+	for _, tt := range tests {
+		f, err := spec.Field(tt.path)
+		if err != nil {
+			t.Errorf("Field(%q): %v", tt.path, err)
+			continue
+		}
+		got := [5]uint32{f.Offset, f.Size, f.BitfieldSize, f.BitShift, f.BitOffset}
+		want := [5]uint32{tt.offset, tt.size, tt.bitfieldSize, tt.bitShift, tt.bo}
+		if got != want {
+			t.Errorf("Field(%q) offset/size/bitfield_size/bit_shift/bit_offset = %v, want %v", tt.path, got, want)
+		}
+	}
+	if _, err := spec.Field("bits.d"); err == nil {
+		t.Error(`Field("bits.d") succeeded, want error`)
+	}
+
+	// 位域的类型大小为 0（损坏的 BTF）时报错，而不是在计算存储单元时除以 0
+	b := btftest.New()
+	zero := b.Int("zero", 0, false)
+	b.Struct("odd", 4, btftest.Member{Name: "z", Type: zero, BitfieldSize: 3})
+	odd, err := Parse(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, err := odd.Field("odd.z"); err == nil {
+		t.Errorf(`Field("odd.z") = %+v, want error`, f)
+	}
+}
@@ -0,0 +1,257 @@
+package btf
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Query 在 vmlinux 及模块的 BTF 上回答按名字的查询。索引在第一次查询时建立一次，之后可以并发使用。
+type Query struct {
+	specs []*Spec // specs[0] 为 vmlinux，其余为以它为 Base 的模块
+
+	once     sync.Once
+	named    []Match            // 所有有名字的类型，按名字排序
+	mentions map[string][]Match // 类型名 -> 签名中提到它的 FUNC
+}
+
+// Match 是一条查询结果。Decl 为 C 声明：FUNC 为函数签名，TYPEDEF 为 "typedef ..."。
+type Match struct {
+	ID     TypeID `json:"id"`
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Module string `json:"module,omitempty"`
+	Size   uint32 `json:"size,omitempty"`
+	Decl   string `json:"decl"`
+	typ    Type
+}
+
+// Type 返回结果对应的类型。
+func (m Match) Type() Type { return m.typ }
+
+// MemberInfo 是 Query.Members 返回的一个成员，偏移与大小的含义同 Field。
+type MemberInfo struct {
+	Name         string `json:"name,omitempty"`
+	Type         string `json:"type"`
+	Decl         string `json:"decl"`
+	Offset       uint32 `json:"offset"`
+	Size         uint32 `json:"size"`
+	BitfieldSize uint32 `json:"bitfield_size,omitempty"`
+	BitShift     uint32 `json:"bit_shift,omitempty"`
+}
+
+// NewQuery 返回 base 与 modules 上的查询。
+func NewQuery(base *Spec, modules ...*Spec) *Query {
+	return &Query{specs: append([]*Spec{base}, modules...)}
+}
+
+func (q *Query) index() {
+	q.once.Do(func() {
+		q.mentions = make(map[string][]Match)
+		for _, spec := range q.specs {
+			for _, t := range spec.Types {
+				if t.TypeName() == "" {
+					continue
+				}
+				switch t.(type) {
+				case *Void, *DeclTag, *TypeTag:
+					continue
+				}
+				m := newMatch(spec, t)
+				q.named = append(q.named, m)
+				if fn, ok := t.(*Func); ok {
+					for _, name := range mentionedNames(fn) {
+						q.mentions[name] = append(q.mentions[name], m)
+					}
+				}
+			}
+		}
+		sort.SliceStable(q.named, func(i, j int) bool { return q.named[i].Name < q.named[j].Name })
+	})
+}
+
+func newMatch(spec *Spec, t Type) Match {
+	m := Match{ID: t.ID(), Kind: t.Kind().String(), Name: t.TypeName(), Module: spec.Module, Decl: declOf(t), typ: t}
+	switch t.(type) {
+	case *Struct, *Union, *Enum, *Enum64, *Int, *Float, *Typedef:
+		m.Size, _ = Sizeof(t)
+	}
+	return m
+}
+
+// declOf 返回 t 的 C 声明。
+func declOf(t Type) string {
+	switch v := t.(type) {
+	case *Typedef:
+		return "typedef " + CDecl(v.Type, v.Name)
+	case *Var:
+		return CDecl(v.Type, v.Name)
+	case *Func:
+		return CDecl(v, "")
+	}
+	return CType(t)
+}
+
+// mentionedNames 返回 fn 的返回值与参数类型中出现的类型名，沿指针、修饰符、typedef 与数组展开，
+// 例如 const skb_frag_t * 提到 skb_frag_t 与 bio_vec。函数指针参数不展开。
+func mentionedNames(fn *Func) []string {
+	if fn.Type == nil {
+		return nil
+	}
+	types := []Type{fn.Type.Return}
+	for _, p := range fn.Type.Params {
+		types = append(types, p.Type)
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, t := range types {
+		for t != nil {
+			if name := t.TypeName(); name != "" && !seen[name] {
+				switch t.(type) {
+				case *Void, *TypeTag:
+					// void 不是可以查询的类型，TYPE_TAG 的名字是标签而不是类型名
+				default:
+					seen[name] = true
+					names = append(names, name)
+				}
+			}
+			t = Target(t)
+		}
+	}
+	return names
+}
+
+// Find 返回名字匹配 pattern 的类型与函数。pattern 默认为 shell 通配符（path.Match 语法，
+// 例如 "tcp_v4_*"），regex 为 true 时为正则表达式（部分匹配，需要完整匹配请加 ^$）。
+// kinds 非空时只返回这些 kind。结果按名字排序。
+func (q *Query) Find(pattern string, regex bool, kinds ...Kind) ([]Match, error) {
+	match, err := compilePattern(pattern, regex)
+	if err != nil {
+		return nil, err
+	}
+	q.index()
+	out := make([]Match, 0)
+	for _, m := range q.named {
+		if len(kinds) > 0 && !hasKind(kinds, m.typ.Kind()) {
+			continue
+		}
+		if match(m.Name) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func compilePattern(pattern string, regex bool) (func(string) bool, error) {
+	if regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("btf: %w", err)
+		}
+		return re.MatchString, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("btf: invalid pattern %q: %w", pattern, err)
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}, nil
+}
+
+func hasKind(kinds []Kind, k Kind) bool {
+	for _, kind := range kinds {
+		if kind == k {
+			return true
+		}
+	}
+	return false
+}
+
+// Members 返回 struct 或 union name（可带 "struct "/"union " 前缀）的成员，匿名成员不展开。
+// 同名类型以 vmlinux 中的为准。
+func (q *Query) Members(name string) ([]MemberInfo, error) {
+	name = trimTagPrefix(name)
+	var t Type
+	for _, spec := range q.specs {
+		for _, kind := range []Kind{KindStruct, KindUnion} {
+			if found, err := spec.TypeByName(name, kind); err == nil {
+				t = found
+				break
+			}
+		}
+		if t != nil {
+			break
+		}
+	}
+	members := compositeMembers(t)
+	if t == nil || members == nil {
+		return nil, fmt.Errorf("btf: struct or union %q not found", name)
+	}
+	out := make([]MemberInfo, 0, len(members))
+	for _, m := range members {
+		info := MemberInfo{Name: m.Name, Type: CType(m.Type), Decl: CDecl(m.Type, m.Name)}
+		if m.Name == "" {
+			info.Offset = m.Offset / 8
+			info.Size, _ = Sizeof(m.Type)
+		} else if f, err := FieldOf(t, m.Name); err == nil {
+			info.Offset, info.Size = f.Offset, f.Size
+			info.BitfieldSize, info.BitShift = f.BitfieldSize, f.BitShift
+		}
+		if info.BitfieldSize > 0 {
+			info.Decl = fmt.Sprintf("%s:%d", info.Decl, info.BitfieldSize)
+		}
+		out = append(out, info)
+	}
+	return out, nil
+}
+
+// Signature 返回名为 name 的 FUNC（可能在多个模块中各有一个）；Match.Decl 为 C 语法的签名。
+func (q *Query) Signature(name string) ([]Match, error) {
+	q.index()
+	out := make([]Match, 0)
+	i := sort.Search(len(q.named), func(i int) bool { return q.named[i].Name >= name })
+	for ; i < len(q.named) && q.named[i].Name == name; i++ {
+		if q.named[i].typ.Kind() == KindFunc {
+			out = append(out, q.named[i])
+		}
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("btf: function %q not found", name)
+	}
+	return out, nil
+}
+
+// FuncsUsing 返回签名（返回值或参数）中提到类型 name 的 FUNC，例如 "sk_buff" 匹配
+// 参数为 const struct sk_buff * 或 struct sk_buff ** 的函数。
+func (q *Query) FuncsUsing(name string) []Match {
+	q.index()
+	out := q.mentions[trimTagPrefix(name)]
+	return append(make([]Match, 0, len(out)), out...)
+}
+
+// Field 按 Spec.Field 解析成员路径，依次在 vmlinux 与各模块中查找根类型。
+func (q *Query) Field(path string) (*Field, error) {
+	var first error
+	for _, spec := range q.specs {
+		f, err := spec.Field(path)
+		if err == nil {
+			return f, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return nil, first
+}
+
+func trimTagPrefix(name string) string {
+	name = strings.TrimSpace(name)
+	for _, prefix := range []string{"struct ", "union ", "enum "} {
+		name = strings.TrimSpace(strings.TrimPrefix(name, prefix))
+	}
+	return name
+}
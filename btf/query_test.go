@@ -0,0 +1,160 @@
+package btf
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Yinzhongkan399/GoServerPS/internal/btftest"
+)
+
+func minimalQuery(t *testing.T) *Query {
+	t.Helper()
+	return NewQuery(loadMinimal(t, "testdata/minimal.btf"))
+}
+
+func matchNames(ms []Match) []string {
+	out := make([]string, 0, len(ms))
+	for _, m := range ms {
+		out = append(out, m.Name)
+	}
+	return out
+}
+
+func TestQueryFind(t *testing.T) {
+	q := minimalQuery(t)
+	tests := []struct {
+		pattern string
+		regex   bool
+		kinds   []Kind
+		want    []string
+	}{
+		{"*", false, nil, []string{"bits", "bits_t", "f", "int", "sgn", "sgn64", "unsigned int", "usg", "usg64"}},
+		{"bits*", false, nil, []string{"bits", "bits_t"}},
+		{"bits*", false, []Kind{KindStruct}, []string{"bits"}},
+		{"?sg", false, nil, []string{"usg"}},
+		{"sgn", false, nil, []string{"sgn"}},
+		{"sgn", true, nil, []string{"sgn", "sgn64"}}, // 正则为部分匹配
+		{"^sgn$", true, nil, []string{"sgn"}},
+		{"64$", true, []Kind{KindEnum64}, []string{"sgn64", "usg64"}},
+		{"64$", true, []Kind{KindEnum}, []string{}},
+		{"void", false, nil, []string{}},
+	}
+	for _, tt := range tests {
+		ms, err := q.Find(tt.pattern, tt.regex, tt.kinds...)
+		if err != nil {
+			t.Errorf("Find(%q, %v): %v", tt.pattern, tt.regex, err)
+			continue
+		}
+		if got := matchNames(ms); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Find(%q, %v, %v) = %q, want %q", tt.pattern, tt.regex, tt.kinds, got, tt.want)
+		}
+	}
+
+	ms, _ := q.Find("bits_t", false)
+	if len(ms) != 1 || ms[0].Decl != "typedef struct bits bits_t" || ms[0].Size != 8 || ms[0].Kind != "TYPEDEF" {
+		t.Errorf("Find(bits_t) = %+v", ms)
+	}
+
+	for _, tt := range []struct {
+		pattern string
+		regex   bool
+	}{{"[", false}, {"(", true}} {
+		if _, err := q.Find(tt.pattern, tt.regex); err == nil {
+			t.Errorf("Find(%q, %v) succeeded, want error", tt.pattern, tt.regex)
+		}
+	}
+}
+
+func TestQueryMembers(t *testing.T) {
+	q := minimalQuery(t)
+	want := []MemberInfo{
+		{Name: "a", Type: "unsigned int", Decl: "unsigned int a:3", Offset: 0, Size: 4, BitfieldSize: 3},
+		{Name: "b", Type: "unsigned int", Decl: "unsigned int b:5", Offset: 0, Size: 4, BitfieldSize: 5, BitShift: 3},
+		{Name: "c", Type: "int", Decl: "int c", Offset: 4, Size: 4},
+	}
+	for _, name := range []string{"bits", "struct bits"} {
+		got, err := q.Members(name)
+		if err != nil {
+			t.Fatalf("Members(%q): %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Members(%q) = %+v, want %+v", name, got, want)
+		}
+	}
+	for _, name := range []string{"sgn", "bits_t", "missing"} {
+		if _, err := q.Members(name); err == nil {
+			t.Errorf("Members(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func TestQuerySignature(t *testing.T) {
+	q := minimalQuery(t)
+	ms, err := q.Signature("f")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ms) != 1 || ms[0].Kind != "FUNC" || ms[0].ID != 11 || ms[0].Decl != "int f(struct bits *p, enum sgn s)" {
+		t.Errorf("Signature(f) = %+v", ms)
+	}
+	for _, name := range []string{"bits", "g"} {
+		if _, err := q.Signature(name); err == nil {
+			t.Errorf("Signature(%q) succeeded, want error", name)
+		}
+	}
+}
+
+func TestQueryFuncsUsing(t *testing.T) {
+	q := minimalQuery(t)
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"bits", []string{"f"}},
+		{"struct bits", []string{"f"}},
+		{"enum sgn", []string{"f"}},
+		{"int", []string{"f"}}, // 返回值
+		{"bits_t", []string{}},
+		{"usg", []string{}},
+	}
+	for _, tt := range tests {
+		if got := matchNames(q.FuncsUsing(tt.name)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FuncsUsing(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestQueryVoid：void 返回值与 void * 参数不应让函数出现在 FuncsUsing("void") 中，void 本身也不是可查找的类型。
+func TestQueryVoid(t *testing.T) {
+	b := btftest.New()
+	i := b.Int("int", 4, true)
+	b.Func("g", b.FuncProto(0, btftest.Param{Name: "p", Type: b.Ptr(0)}, btftest.Param{Name: "n", Type: i}), btftest.Global)
+	spec, err := Parse(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := NewQuery(spec)
+	if ms, _ := q.Find("*", false); !reflect.DeepEqual(matchNames(ms), []string{"g", "int"}) {
+		t.Errorf("Find(*) = %q, want g and int", matchNames(ms))
+	}
+	if ms := q.FuncsUsing("void"); len(ms) != 0 {
+		t.Errorf("FuncsUsing(void) = %q, want none", matchNames(ms))
+	}
+	if got := matchNames(q.FuncsUsing("int")); !reflect.DeepEqual(got, []string{"g"}) {
+		t.Errorf("FuncsUsing(int) = %q, want g", got)
+	}
+}
+
+func TestQueryField(t *testing.T) {
+	q := minimalQuery(t)
+	f, err := q.Field("bits_t.b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Offset != 0 || f.Size != 4 || f.BitfieldSize != 5 || f.BitShift != 3 {
+		t.Errorf("Field(bits_t.b) = %+v", f)
+	}
+	if _, err := q.Field("bits.d"); err == nil {
+		t.Error(`Field("bits.d") succeeded, want error`)
+	}
+}
@@ -0,0 +1,124 @@
+//go:build ignore
+// +build ignore
+
+// gen 生成 load_test.go 使用的 BTF fixture：同一份手工编码的 BTF 分别保存为
+// 原始 blob、带 .BTF 节的 ELF、.tar.gz 与 .tar.xz（需要 PATH 中有 xz）。
+// 在 btf 目录下运行 go generate 即可重新生成。
+//
+// 类型对应的 C 代码：
+//
+//	enum sgn { S_NEG = -1, S_POS = 1 };            // kind_flag=1
+//	enum usg { U_MAX = 0xffffffffu };              // kind_flag=0
+//	enum sgn64 { N64 = -2LL };                     // ENUM64，kind_flag=1
+//	enum usg64 { BIG = 0xfffffffffffffffeULL };    // ENUM64，kind_flag=0
+//	struct bits { unsigned int a:3, b:5; int c; }; // kind_flag=1，位域宽度在 offset 高 8 位
+//	typedef struct bits bits_t;
+//	int f(struct bits *p, enum sgn s);
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"encoding/binary"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/Yinzhongkan399/GoServerPS/internal/btftest"
+)
+
+var le = binary.LittleEndian
+
+func encode() []byte {
+	b := btftest.New()
+	i := b.Int("int", 4, true)           // [1]
+	u := b.Int("unsigned int", 4, false) // [2]
+	sgn := b.Enum("sgn", 4, true,        // [3]
+		btftest.EnumValue{Name: "S_NEG", Value: -1}, btftest.EnumValue{Name: "S_POS", Value: 1})
+	b.Enum("usg", 4, false, btftest.EnumValue{Name: "U_MAX", Value: 0xffffffff}) // [4]
+	b.Enum64("sgn64", 8, true, btftest.EnumValue{Name: "N64", Value: -2})        // [5]
+	b.Enum64("usg64", 8, false, btftest.EnumValue{Name: "BIG", Value: -2})       // [6]
+	bits := b.Struct("bits", 8,                                                  // [7]
+		btftest.Member{Name: "a", Type: u, BitfieldSize: 3},
+		btftest.Member{Name: "b", Type: u, BitOffset: 3, BitfieldSize: 5},
+		btftest.Member{Name: "c", Type: i, BitOffset: 32})
+	p := b.Ptr(bits)                                                                                // [8]
+	b.Typedef("bits_t", bits)                                                                       // [9]
+	proto := b.FuncProto(i, btftest.Param{Name: "p", Type: p}, btftest.Param{Name: "s", Type: sgn}) // [10]
+	b.Func("f", proto, btftest.Global)                                                              // [11]
+	return b.Bytes()
+}
+
+// wrapELF 把 blob 放进一个只有 .BTF 与 .shstrtab 两个节的 ELF64 可重定位文件。
+func wrapELF(blob []byte) []byte {
+	const ehsize, shentsize = 64, 64
+	shstrtab := []byte("\x00.BTF\x00.shstrtab\x00")
+	btfOff := uint64(ehsize)
+	strOff := btfOff + uint64(len(blob))
+	shoff := (strOff + uint64(len(shstrtab)) + 7) &^ 7
+
+	var b bytes.Buffer
+	hdr := elf.Header64{
+		Type: uint16(elf.ET_REL), Machine: uint16(elf.EM_BPF), Version: uint32(elf.EV_CURRENT),
+		Shoff: shoff, Ehsize: ehsize, Shentsize: shentsize, Shnum: 3, Shstrndx: 2,
+	}
+	copy(hdr.Ident[:], elf.ELFMAG)
+	hdr.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	hdr.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	hdr.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	binary.Write(&b, le, hdr)
+	b.Write(blob)
+	b.Write(shstrtab)
+	b.Write(make([]byte, shoff-uint64(b.Len())))
+	binary.Write(&b, le, []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_PROGBITS), Off: btfOff, Size: uint64(len(blob)), Addralign: 4},
+		{Name: 6, Type: uint32(elf.SHT_STRTAB), Off: strOff, Size: uint64(len(shstrtab)), Addralign: 1},
+	})
+	return b.Bytes()
+}
+
+// wrapTar 模仿 BTFHub 的归档：一个 README 加上以 .btf 结尾的 BTF 文件。
+func wrapTar(name string, blob []byte) []byte {
+	var b bytes.Buffer
+	tw := tar.NewWriter(&b)
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{"README", []byte("generated by btf/testdata/gen.go\n")}, {name, blob}} {
+		tw.WriteHeader(&tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data)), Typeflag: tar.TypeReg})
+		tw.Write(f.data)
+	}
+	tw.Close()
+	return b.Bytes()
+}
+
+func main() {
+	blob := encode()
+	tarball := wrapTar("minimal.btf", blob)
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write(tarball)
+	zw.Close()
+
+	cmd := exec.Command("xz", "-c")
+	cmd.Stdin = bytes.NewReader(tarball)
+	xz, err := cmd.Output()
+	if err != nil {
+		log.Fatalf("xz: %v", err)
+	}
+
+	for name, data := range map[string][]byte{
+		"minimal.btf":        blob,
+		"minimal.o":          wrapELF(blob),
+		"minimal.btf.tar.gz": gz.Bytes(),
+		"minimal.btf.tar.xz": xz,
+	} {
+		if err := os.WriteFile("testdata/"+name, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package btf
+
+import "fmt"
+
+// Type 是解析后的 BTF 类型。具体类型为 *Void、*Int、*Ptr、*Array、*Struct、*Union、*Enum、
+// *Enum64、*Fwd、*Typedef、*Volatile、*Const、*Restrict、*Func、*FuncProto、*Var、
+// *Datasec、*Float、*DeclTag、*TypeTag；对其他类型的引用已经解析为 Type。
+type Type interface {
+	ID() TypeID
+	TypeName() string
+	Kind() Kind
+}
+
+// typeID 嵌入到每个具体类型中，提供 ID()。
+type typeID struct{ id TypeID }
+
+func (t typeID) ID() TypeID { return t.id }
+
+// Void 是 ID 0，也用作变参函数最后一个参数的类型。
+type Void struct{ typeID }
+
+func (*Void) TypeName() string { return "void" }
+func (*Void) Kind() Kind       { return KindUnknown }
+
+// IntEncoding 是 BTF_INT_ENCODING 的标志位。
+type IntEncoding uint8
+
+const (
+	IntSigned IntEncoding = 1 << iota
+	IntChar
+	IntBool
+)
+
+type Int struct {
+	typeID
+	Name     string
+	Size     uint32 // 字节
+	Encoding IntEncoding
+	Offset   uint32 // 位偏移，内核生成的 BTF 中总是 0
+	Bits     uint32
+}
+
+func (t *Int) TypeName() string { return t.Name }
+func (*Int) Kind() Kind         { return KindInt }
+
+type Float struct {
+	typeID
+	Name string
+	Size uint32
+}
+
+func (t *Float) TypeName() string { return t.Name }
+func (*Float) Kind() Kind         { return KindFloat }
+
+type Ptr struct {
+	typeID
+	Target Type
+}
+
+func (*Ptr) TypeName() string { return "" }
+func (*Ptr) Kind() Kind       { return KindPtr }
+
+type Array struct {
+	typeID
+	Type   Type // 元素类型
+	Index  Type
+	Nelems uint32
+}
+
+func (*Array) TypeName() string { return "" }
+func (*Array) Kind() Kind       { return KindArray }
+
+// Member 是 struct/union 的成员。Offset 以位为单位；BitfieldSize 非 0 表示位域。
+type Member struct {
+	Name         string
+	Type         Type
+	Offset       uint32
+	BitfieldSize uint32
+}
+
+type Struct struct {
+	typeID
+	Name    string
+	Size    uint32
+	Members []Member
+}
+
+func (t *Struct) TypeName() string { return t.Name }
+func (*Struct) Kind() Kind         { return KindStruct }
+
+type Union struct {
+	typeID
+	Name    string
+	Size    uint32
+	Members []Member
+}
+
+func (t *Union) TypeName() string { return t.Name }
+func (*Union) Kind() Kind         { return KindUnion }
+
+// EnumValue 是一个枚举常量。ENUM64 的无符号值按位保存在 int64 中。
+type EnumValue struct {
+	Name  string
+	Value int64
+}
+
+type Enum struct {
+	typeID
+	Name   string
+	Size   uint32
+	Signed bool
+	Values []EnumValue
+}
+
+func (t *Enum) TypeName() string { return t.Name }
+func (*Enum) Kind() Kind         { return KindEnum }
+
+type Enum64 struct {
+	typeID
+	Name   string
+	Size   uint32
+	Signed bool
+	Values []EnumValue
+}
+
+func (t *Enum64) TypeName() string { return t.Name }
+func (*Enum64) Kind() Kind         { return KindEnum64 }
+
+// Fwd 是前向声明 "struct foo;" 或 "union foo;"。
+type Fwd struct {
+	typeID
+	Name  string
+	Union bool
+}
+
+func (t *Fwd) TypeName() string { return t.Name }
+func (*Fwd) Kind() Kind         { return KindFwd }
+
+type Typedef struct {
+	typeID
+	Name string
+	Type Type
+}
+
+func (t *Typedef) TypeName() string { return t.Name }
+func (*Typedef) Kind() Kind         { return KindTypedef }
+
+type Volatile struct {
+	typeID
+	Type Type
+}
+
+func (*Volatile) TypeName() string { return "" }
+func (*Volatile) Kind() Kind       { return KindVolatile }
+
+type Const struct {
+	typeID
+	Type Type
+}
+
+func (*Const) TypeName() string { return "" }
+func (*Const) Kind() Kind       { return KindConst }
+
+type Restrict struct {
+	typeID
+	Type Type
+}
+
+func (*Restrict) TypeName() string { return "" }
+func (*Restrict) Kind() Kind       { return KindRestrict }
+
+// Linkage 是 FUNC、VAR 的链接属性。
+type Linkage uint32
+
+const (
+	StaticLinkage Linkage = iota
+	GlobalLinkage
+	ExternLinkage
+)
+
+// String 返回 bpftool 使用的名字："static"、"global"、"extern"。
+func (l Linkage) String() string {
+	if int(l) < len(linkageNames) {
+		return linkageNames[l]
+	}
+	return fmt.Sprintf("linkage(%d)", uint32(l))
+}
+
+var linkageNames = [...]string{"static", "global", "extern"}
+
+type Func struct {
+	typeID
+	Name    string
+	Type    *FuncProto
+	Linkage Linkage
+}
+
+func (t *Func) TypeName() string { return t.Name }
+func (*Func) Kind() Kind         { return KindFunc }
+
+// FuncParam 是函数参数。变参函数的最后一个参数名为空、类型为 *Void。
+type FuncParam struct {
+	Name string
+	Type Type
+}
+
+type FuncProto struct {
+	typeID
+	Return Type
+	Params []FuncParam
+}
+
+func (*FuncProto) TypeName() string { return "" }
+func (*FuncProto) Kind() Kind       { return KindFuncProto }
+
+type Var struct {
+	typeID
+	Name    string
+	Type    Type
+	Linkage Linkage
+}
+
+func (t *Var) TypeName() string { return t.Name }
+func (*Var) Kind() Kind         { return KindVar }
+
+type VarSecinfo struct {
+	Type   Type
+	Offset uint32
+	Size   uint32
+}
+
+type Datasec struct {
+	typeID
+	Name string
+	Size uint32
+	Vars []VarSecinfo
+}
+
+func (t *Datasec) TypeName() string { return t.Name }
+func (*Datasec) Kind() Kind         { return KindDatasec }
+
+// DeclTag 是 __attribute__((btf_decl_tag("...")))。Index 为 -1 表示修饰 Type 本身，
+// 否则为成员或参数的序号。
+type DeclTag struct {
+	typeID
+	Value string
+	Type  Type
+	Index int
+}
+
+func (t *DeclTag) TypeName() string { return t.Value }
+func (*DeclTag) Kind() Kind         { return KindDeclTag }
+
+// TypeTag 是 __attribute__((btf_type_tag("...")))，例如 __user、__rcu。
+type TypeTag struct {
+	typeID
+	Value string
+	Type  Type
+}
+
+func (t *TypeTag) TypeName() string { return t.Value }
+func (*TypeTag) Kind() Kind         { return KindTypeTag }
+
+// Target 返回修饰类与指针类型直接引用的类型（Ptr、Typedef、Volatile、Const、Restrict、TypeTag、
+// Array 的元素类型、Var），其他类型返回 nil。
+func Target(t Type) Type {
+	switch v := t.(type) {
+	case *Ptr:
+		return v.Target
+	case *Typedef:
+		return v.Type
+	case *Volatile:
+		return v.Type
+	case *Const:
+		return v.Type
+	case *Restrict:
+		return v.Type
+	case *TypeTag:
+		return v.Type
+	case *Array:
+		return v.Type
+	case *Var:
+		return v.Type
+	}
+	return nil
+}
+
+// References 返回 t 直接引用的所有类型：Target 的结果、struct/union 的成员类型、
+// FUNC_PROTO 的返回值与参数类型、FUNC 的原型。其他类型返回 nil。
+func References(t Type) []Type {
+	switch v := t.(type) {
+	case *Struct:
+		return memberTypes(v.Members)
+	case *Union:
+		return memberTypes(v.Members)
+	case *FuncProto:
+		refs := make([]Type, 0, len(v.Params)+1)
+		refs = append(refs, v.Return)
+		for _, p := range v.Params {
+			refs = append(refs, p.Type)
+		}
+		return refs
+	case *Func:
+		return []Type{v.Type}
+	}
+	if target := Target(t); target != nil {
+		return []Type{target}
+	}
+	return nil
+}
+
+func memberTypes(members []Member) []Type {
+	refs := make([]Type, len(members))
+	for i, m := range members {
+		refs[i] = m.Type
+	}
+	return refs
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/Yinzhongkan399/GoServerPS/baserun"
+	"github.com/Yinzhongkan399/GoServerPS/btf"
+)
+
+const btfUsage = `usage: goserverps btf [flags] <op> [flags] ARG
+
+ops:
+  find    PATTERN  types and functions whose name matches a glob (-regex: a regular expression)
+  members TYPE     members of a struct or union with offsets and sizes
+  sig     FUNC     C signature of a function
+  using   TYPE     functions whose return or parameter types mention TYPE
+  field   PATH     offset, size and bitfield layout of a member path, e.g. sock.__sk_common.skc_daddr
+`
+
+// btfOps 是 btf 子命令与 /api/btf/query 共用的操作。
+var btfOps = map[string]func(q *btf.Query, arg string, kinds []btf.Kind, regex bool) (interface{}, error){
+	"find": func(q *btf.Query, arg string, kinds []btf.Kind, regex bool) (interface{}, error) {
+		return q.Find(arg, regex, kinds...)
+	},
+	"members": func(q *btf.Query, arg string, _ []btf.Kind, _ bool) (interface{}, error) {
+		return q.Members(arg)
+	},
+	"sig": func(q *btf.Query, arg string, _ []btf.Kind, _ bool) (interface{}, error) {
+		return q.Signature(arg)
+	},
+	"using": func(q *btf.Query, arg string, _ []btf.Kind, _ bool) (interface{}, error) {
+		return q.FuncsUsing(arg), nil
+	},
+	"field": func(q *btf.Query, arg string, _ []btf.Kind, _ bool) (interface{}, error) {
+		return q.Field(arg)
+	},
+}
+
+// runBTFQuery 执行 op；返回的错误都是参数错误或查无结果。
+func runBTFQuery(q *btf.Query, op, arg string, kinds []btf.Kind, regex bool) (interface{}, error) {
+	fn, ok := btfOps[op]
+	if !ok {
+		return nil, fmt.Errorf("unknown btf op %q (find, members, sig, using, field)", op)
+	}
+	if arg == "" {
+		return nil, fmt.Errorf("btf %s: missing argument", op)
+	}
+	return fn(q, arg, kinds, regex)
+}
+
+// parseKinds 解析逗号分隔的 kind 列表，例如 "func,struct"。
+func parseKinds(v string) ([]btf.Kind, error) {
+	var kinds []btf.Kind
+	for _, name := range splitList(v) {
+		k, err := btf.ParseKind(name)
+		if err != nil {
+			return nil, err
+		}
+		kinds = append(kinds, k)
+	}
+	return kinds, nil
+}
+
+// btfCmd 在 vmlinux 与模块的 BTF 上执行一次查询并打印 JSON；sig 打印 C 声明。
+func btfCmd(args []string) error {
+	fs := flag.NewFlagSet("btf", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), btfUsage+"\nflags:\n")
+		fs.PrintDefaults()
+	}
+	var opts baserun.Options
+	registerBTFSourceFlags(fs, &opts)
+	kind := fs.String("kind", "", "find: comma-separated kinds to return, e.g. func,struct,typedef")
+	regex := fs.Bool("regex", false, "find: PATTERN is a regular expression instead of a glob")
+	// flag 在第一个非 flag 参数处停止：op 之前与之后的 flag 都接受
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	op := fs.Arg(0)
+	if _, ok := btfOps[op]; !ok {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fs.Parse(fs.Args()[1:])
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	kinds, err := parseKinds(*kind)
+	if err != nil {
+		return err
+	}
+
+	q, err := opts.Query()
+	if err != nil {
+		return err
+	}
+	res, err := runBTFQuery(q, op, fs.Arg(0), kinds, *regex)
+	if err != nil {
+		return err
+	}
+	if sigs, ok := res.([]btf.Match); ok && op == "sig" {
+		for _, m := range sigs {
+			if m.Module != "" {
+				fmt.Printf("%s; /* %s */\n", m.Decl, m.Module)
+				continue
+			}
+			fmt.Printf("%s;\n", m.Decl)
+		}
+		return nil
+	}
+	return printJSON(res)
+}
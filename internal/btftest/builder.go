@@ -0,0 +1,215 @@
+// Package btftest 生成小型的原始 BTF（小端），供 btf/testdata/gen.go 与各包的测试使用。
+//
+// 本包不依赖 btf 包，btf 自己的测试也可以使用；类型 ID 因此直接用 uint32 表示。
+package btftest
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// BTF_KIND_*，与 btf.Kind 相同。
+const (
+	kindInt       = 1
+	kindPtr       = 2
+	kindArray     = 3
+	kindStruct    = 4
+	kindUnion     = 5
+	kindEnum      = 6
+	kindFwd       = 7
+	kindTypedef   = 8
+	kindVolatile  = 9
+	kindConst     = 10
+	kindRestrict  = 11
+	kindFunc      = 12
+	kindFuncProto = 13
+	kindEnum64    = 19
+)
+
+// Linkage 是 FUNC 的 BTF_FUNC_*。
+const (
+	Static = 0
+	Global = 1
+)
+
+var le = binary.LittleEndian
+
+// Builder 按调用顺序编码类型，每个方法返回新类型的 ID。引用可以指向尚未添加的 ID（见 NextID）。
+type Builder struct {
+	types   bytes.Buffer
+	strings bytes.Buffer
+	offsets map[string]uint32
+	firstID uint32
+	strBase uint32
+	n       uint32
+}
+
+// New 返回独立 BTF 的 Builder：ID 从 1 开始，0 为 void。
+func New() *Builder {
+	b := &Builder{offsets: make(map[string]uint32), firstID: 1}
+	b.strings.WriteByte(0)
+	return b
+}
+
+// NewSplit 返回以 base 为基础的 split BTF（内核模块的格式）的 Builder：ID 接在 base 的最后一个 ID 之后，
+// 字符串偏移接在 base 的字符串段之后。之后不能再向 base 添加类型。
+func NewSplit(base *Builder) *Builder {
+	return &Builder{offsets: make(map[string]uint32), firstID: base.NextID(), strBase: base.strBase + uint32(base.strings.Len())}
+}
+
+// NextID 返回下一个添加的类型的 ID。
+func (b *Builder) NextID() uint32 { return b.firstID + b.n }
+
+func (b *Builder) str(s string) uint32 {
+	if s == "" {
+		return 0
+	}
+	if off, ok := b.offsets[s]; ok {
+		return off
+	}
+	off := b.strBase + uint32(b.strings.Len())
+	b.strings.WriteString(s + "\x00")
+	b.offsets[s] = off
+	return off
+}
+
+func (b *Builder) u32(vs ...uint32) {
+	for _, v := range vs {
+		binary.Write(&b.types, le, v)
+	}
+}
+
+// typ 写入 btf_type：name_off、info（kind、kind_flag、vlen）、size/type。
+func (b *Builder) typ(name string, kind, vlen int, kindFlag bool, sizeType uint32) uint32 {
+	info := uint32(kind)<<24 | uint32(vlen)
+	if kindFlag {
+		info |= 1 << 31
+	}
+	b.u32(b.str(name), info, sizeType)
+	id := b.NextID()
+	b.n++
+	return id
+}
+
+// Int 添加 bits 位的整数（BTF_INT_SIGNED 由 signed 决定）。
+func (b *Builder) Int(name string, size uint32, signed bool) uint32 {
+	id := b.typ(name, kindInt, 0, false, size)
+	enc := size * 8
+	if signed {
+		enc |= 1 << 24
+	}
+	b.u32(enc)
+	return id
+}
+
+func (b *Builder) Ptr(t uint32) uint32      { return b.typ("", kindPtr, 0, false, t) }
+func (b *Builder) Const(t uint32) uint32    { return b.typ("", kindConst, 0, false, t) }
+func (b *Builder) Volatile(t uint32) uint32 { return b.typ("", kindVolatile, 0, false, t) }
+func (b *Builder) Restrict(t uint32) uint32 { return b.typ("", kindRestrict, 0, false, t) }
+
+func (b *Builder) Typedef(name string, t uint32) uint32 {
+	return b.typ(name, kindTypedef, 0, false, t)
+}
+
+// Fwd 添加前向声明，union 为 true 时是 union。
+func (b *Builder) Fwd(name string, union bool) uint32 {
+	return b.typ(name, kindFwd, 0, union, 0)
+}
+
+func (b *Builder) Array(elem, index, nelems uint32) uint32 {
+	id := b.typ("", kindArray, 0, false, 0)
+	b.u32(elem, index, nelems)
+	return id
+}
+
+// Member 是 struct/union 的成员。BitfieldSize 非 0 时以 kind_flag 格式编码。
+type Member struct {
+	Name         string
+	Type         uint32
+	BitOffset    uint32
+	BitfieldSize uint32
+}
+
+func (b *Builder) Struct(name string, size uint32, members ...Member) uint32 {
+	return b.composite(kindStruct, name, size, members)
+}
+
+func (b *Builder) Union(name string, size uint32, members ...Member) uint32 {
+	return b.composite(kindUnion, name, size, members)
+}
+
+func (b *Builder) composite(kind int, name string, size uint32, members []Member) uint32 {
+	kindFlag := false
+	for _, m := range members {
+		kindFlag = kindFlag || m.BitfieldSize > 0
+	}
+	id := b.typ(name, kind, len(members), kindFlag, size)
+	for _, m := range members {
+		off := m.BitOffset
+		if kindFlag {
+			off |= m.BitfieldSize << 24
+		}
+		b.u32(b.str(m.Name), m.Type, off)
+	}
+	return id
+}
+
+// EnumValue 是枚举的一个值。
+type EnumValue struct {
+	Name  string
+	Value int64
+}
+
+// Enum 添加 ENUM，signed 对应 kind_flag。值按 32 位截断保存。
+func (b *Builder) Enum(name string, size uint32, signed bool, values ...EnumValue) uint32 {
+	id := b.typ(name, kindEnum, len(values), signed, size)
+	for _, v := range values {
+		b.u32(b.str(v.Name), uint32(v.Value))
+	}
+	return id
+}
+
+// Enum64 添加 ENUM64，值按 val_lo32、val_hi32 保存。
+func (b *Builder) Enum64(name string, size uint32, signed bool, values ...EnumValue) uint32 {
+	id := b.typ(name, kindEnum64, len(values), signed, size)
+	for _, v := range values {
+		b.u32(b.str(v.Name), uint32(v.Value), uint32(uint64(v.Value)>>32))
+	}
+	return id
+}
+
+// Param 是 FUNC_PROTO 的参数。
+type Param struct {
+	Name string
+	Type uint32
+}
+
+func (b *Builder) FuncProto(ret uint32, params ...Param) uint32 {
+	id := b.typ("", kindFuncProto, len(params), false, ret)
+	for _, p := range params {
+		b.u32(b.str(p.Name), p.Type)
+	}
+	return id
+}
+
+// Func 添加 FUNC，linkage 为 Static 或 Global。
+func (b *Builder) Func(name string, proto uint32, linkage int) uint32 {
+	return b.typ(name, kindFunc, linkage, false, proto)
+}
+
+// Bytes 返回带 btf_header 的原始 BTF。
+func (b *Builder) Bytes() []byte {
+	var out bytes.Buffer
+	binary.Write(&out, le, struct {
+		Magic          uint16
+		Version, Flags uint8
+		HdrLen         uint32
+		TypeOff        uint32
+		TypeLen        uint32
+		StrOff         uint32
+		StrLen         uint32
+	}{0xeb9f, 1, 0, 24, 0, uint32(b.types.Len()), uint32(b.types.Len()), uint32(b.strings.Len())})
+	out.Write(b.types.Bytes())
+	out.Write(b.strings.Bytes())
+	return out.Bytes()
+}
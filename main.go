@@ -1,30 +1,191 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/Yinzhongkan399/GoServerPS/baserun"
+	"github.com/Yinzhongkan399/GoServerPS/server"
 )
 
+const usage = `usage: goserverps [command] [flags]
+
+commands:
+  run     run BaseRun, ReadBTFandGetItsMember and TranslateJSON once (default)
+  serve   run the pipeline, then serve the JSON API over HTTP
+  related print the related types and functions of each -seeds type as JSON
+  btf     query the BTF: find types, list members, show signatures and users of a type
+  purge   delete the cached BTF artifacts (-dbs: also the capture databases)
+  sockets print the socket list as JSON
+  diff    print sockets added, removed or changed between two snapshots
+  watch   print socket changes continuously
+  summary print socket counts per protocol, state, peer, listener and process
+  interfaces
+          print interface counters and attributes as JSON
+`
+
 func main() {
+	cmd := "run"
+	args := os.Args[1:]
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "run":
+		err = runCmd(args)
+	case "serve":
+		err = serveCmd(args)
+	case "related":
+		err = relatedCmd(args)
+	case "btf":
+		err = btfCmd(args)
+	case "purge":
+		err = purgeCmd(args)
+	case "sockets":
+		err = socketsCmd(args)
+	case "diff":
+		err = diffCmd(args)
+	case "watch":
+		err = watchCmd(args)
+	case "summary":
+		err = summaryCmd(args)
+	case "interfaces":
+		err = interfacesCmd(args)
+	case "help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// registerBTFSourceFlags 注册 BTF 来源参数：-btf、-btf-modules 与 -release。
+func registerBTFSourceFlags(fs *flag.FlagSet, opts *baserun.Options) {
+	fs.StringVar(&opts.BTFFile, "btf", "", "vmlinux BTF: raw blob, ELF with .BTF, or .btf.tar.xz/.tar.gz (default /sys/kernel/btf/vmlinux)")
+	fs.StringVar(&opts.ModuleDir, "btf-modules", "", "directory of module split BTF files (default /sys/kernel/btf unless -btf is set)")
+	fs.StringVar(&opts.Release, "release", "", "kernel release used in the cache key (default: running kernel, or the -btf file name)")
+}
+
+// registerBTFFlags 注册 run、serve 与 related 共用的参数：BTF 来源以及相关类型闭包的 -seeds、-depth。
+func registerBTFFlags(fs *flag.FlagSet, opts *baserun.Options) {
+	registerBTFSourceFlags(fs, opts)
+	fs.Func("seeds", "comma-separated seed types of the related-type closure, e.g. sk_buff,sock,net_device (default sk_buff)", func(v string) error {
+		opts.Seeds = splitList(v)
+		return nil
+	})
+	fs.IntVar(&opts.Depth, "depth", 0, "max struct/union/func-proto levels between a related type and its seed (0 = 5, -1 = unlimited)")
+}
+
+// splitList 拆分逗号分隔的列表，忽略空项。
+func splitList(v string) []string {
+	var out []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// relatedCmd 按种子分别打印相关类型与相关函数，不写 .cache。
+func relatedCmd(args []string) error {
+	fs := flag.NewFlagSet("related", flag.ExitOnError)
+	var opts baserun.Options
+	registerBTFFlags(fs, &opts)
+	funcsOnly := fs.Bool("funcs", false, "omit the related types")
+	fs.Parse(args)
+
+	results, err := opts.Related()
+	if err != nil {
+		return err
+	}
+	if *funcsOnly {
+		for i := range results {
+			results[i].Types = nil
+		}
+	}
+	return printJSON(results)
+}
+
+// purgeCmd 删除 .cache 中的 BTF 缓存；-dbs 时连同采集数据库及其轮转副本一起删除。
+func purgeCmd(args []string) error {
+	fs := flag.NewFlagSet("purge", flag.ExitOnError)
+	dbs := fs.Bool("dbs", false, "also delete FunctionInfo.db, PacketInfo.db and .cache/archive")
+	fs.Parse(args)
+
+	removed, err := baserun.Purge(*dbs)
+	for _, p := range removed {
+		log.Printf("removed %s", p)
+	}
+	return err
+}
+
+func runCmd(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var opts baserun.Options
+	registerBTFFlags(fs, &opts)
+	fs.Parse(args)
+	return runPipeline(&opts)
+}
+
+func runPipeline(opts *baserun.Options) error {
 	log.Println("Starting BaseRun()")
-	if err := baserun.BaseRun(); err != nil {
-		log.Fatalf("BaseRun failed: %v", err)
+	if err := opts.BaseRun(); err != nil {
+		return fmt.Errorf("BaseRun failed: %w", err)
 	}
 	log.Println("BaseRun completed")
 
 	log.Println("Running ReadBTFandGetItsMember()")
-	funcs, err := baserun.ReadBTFandGetItsMember()
+	funcs, err := opts.ReadBTFandGetItsMember()
 	if err != nil {
-		log.Fatalf("ReadBTFandGetItsMember failed: %v", err)
+		return fmt.Errorf("ReadBTFandGetItsMember failed: %w", err)
 	}
 	log.Printf("ReadBTFandGetItsMember returned %d entries", len(funcs))
 
 	log.Println("Running TranslateJSON()")
-	if err := baserun.TranslateJSON(); err != nil {
-		log.Fatalf("TranslateJSON failed: %v", err)
+	if err := opts.TranslateJSON(); err != nil {
+		return fmt.Errorf("TranslateJSON failed: %w", err)
 	}
 	log.Println("TranslateJSON completed")
 
 	log.Println("All steps finished successfully")
+	return nil
+}
+
+func serveCmd(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8080", "listen address")
+	pipeline := fs.Bool("pipeline", true, "run the BTF pipeline before serving")
+	var sf socketFlags
+	sf.register(fs)
+	var opts baserun.Options
+	registerBTFFlags(fs, &opts)
+	fs.Parse(args)
+	if _, err := sf.lister(); err != nil {
+		return err
+	}
+
+	if *pipeline {
+		if err := runPipeline(&opts); err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	srv := server.New(*addr)
+	registerRoutes(srv.Router, sf, &opts)
+	return srv.ListenAndServe(ctx)
 }
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Yinzhongkan399/GoServerPS/baserun"
+	"github.com/Yinzhongkan399/GoServerPS/btf"
+	"github.com/Yinzhongkan399/GoServerPS/server"
+	"github.com/Yinzhongkan399/GoServerPS/socklist"
+)
+
+const (
+	relatedFuncPath = "./.cache/relatedFuncD5.json"
+	funcIDMapPath   = "./.cache/FuncIDMap.json"
+)
+
+// registerRoutes 把项目的各功能模块挂到路由表上。新增接口只需在这里 Register。
+// sf 为 socket 相关接口的默认参数，opts 为 BTF 来源（均来自 serve 的命令行）。
+func registerRoutes(rt *server.Router, sf socketFlags, opts *baserun.Options) {
+	rt.Register(http.MethodGet, "/api/health", func(w http.ResponseWriter, r *http.Request) error {
+		return server.WriteJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+
+	rt.Register(http.MethodGet, "/api/sockets", func(w http.ResponseWriter, r *http.Request) error {
+		l, err := listerFromQuery(sf, r.URL.Query())
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		socks, err := listSockets(l)
+		if err != nil {
+			return err
+		}
+		return server.WriteJSON(w, http.StatusOK, socks)
+	})
+	// 旧版 ListAll 的位置数组格式。
+	rt.Register(http.MethodGet, "/api/listall", func(w http.ResponseWriter, r *http.Request) error {
+		l, err := listerFromQuery(sf, r.URL.Query())
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		s, err := l.ListAll()
+		if err != nil {
+			return err
+		}
+		return server.WriteJSON(w, http.StatusOK, json.RawMessage(s))
+	})
+	// ss -s 风格的汇总；format=table 返回文本表格。
+	rt.Register(http.MethodGet, "/api/sockets/summary", func(w http.ResponseWriter, r *http.Request) error {
+		q := r.URL.Query()
+		l, err := listerFromQuery(sf, q)
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		top, err := topFromQuery(q)
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		snap, err := l.Snapshot()
+		if err != nil {
+			return err
+		}
+		sum := socklist.Summarize(snap, top)
+		if q.Get("format") == "table" {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			return sum.WriteTable(w)
+		}
+		return server.WriteJSON(w, http.StatusOK, sum)
+	})
+
+	// GET 与同一组参数（backend、netns、filter 以及 ?client= 令牌）上一次调用本接口时的快照比较，
+	// 首次调用时全部算新增；多个客户端应各自带上不同的 client。
+	// POST 的请求体为客户端保存的上一次快照，返回 diff 以及新的快照。
+	var diffMu sync.Mutex
+	baselines := make(map[string]*diffBaseline)
+	rt.Register(http.MethodGet, "/api/sockets/diff", func(w http.ResponseWriter, r *http.Request) error {
+		q := r.URL.Query()
+		f := flagsFromQuery(sf, q)
+		l, err := f.lister()
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		cur, err := l.Snapshot()
+		if err != nil {
+			return err
+		}
+		key := f.queryKey() + "|" + q.Get("client")
+		diffMu.Lock()
+		var prev *socklist.Snapshot
+		if b := baselines[key]; b != nil {
+			prev = b.snap
+		}
+		storeBaseline(baselines, key, cur)
+		diffMu.Unlock()
+		return server.WriteJSON(w, http.StatusOK, socklist.Diff(prev, cur))
+	})
+	rt.Register(http.MethodPost, "/api/sockets/diff", func(w http.ResponseWriter, r *http.Request) error {
+		var prev socklist.Snapshot
+		if err := json.NewDecoder(r.Body).Decode(&prev); err != nil {
+			return server.Errorf(http.StatusBadRequest, "decode snapshot: %v", err)
+		}
+		l, err := listerFromQuery(sf, r.URL.Query())
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		cur, err := l.Snapshot()
+		if err != nil {
+			return err
+		}
+		return server.WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"diff":     socklist.Diff(&prev, cur),
+			"snapshot": cur,
+		})
+	})
+
+	rt.Register(http.MethodGet, "/api/sockets/watch", func(w http.ResponseWriter, r *http.Request) error {
+		l, err := listerFromQuery(sf, r.URL.Query())
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		interval := 2 * time.Second
+		if v := r.URL.Query().Get("interval"); v != "" {
+			if interval, err = time.ParseDuration(v); err != nil || interval <= 0 {
+				return server.Errorf(http.StatusBadRequest, "invalid interval %q", v)
+			}
+		}
+		return watchSSE(w, r, l, interval)
+	})
+
+	rt.Register(http.MethodGet, "/api/interfaces", func(w http.ResponseWriter, r *http.Request) error {
+		l, err := listerFromQuery(sf, r.URL.Query())
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		ifs, err := l.ListInterfaces()
+		if err != nil {
+			return err
+		}
+		return server.WriteJSON(w, http.StatusOK, ifs)
+	})
+
+	// GET 返回以启动参数生成的 relatedFuncD5.json；POST 重新生成后返回。
+	// POST 带 ?seeds=、?depth= 时按这些参数计算并返回，但不改写 relatedFuncD5.json，
+	// 因此 GET /api/btf/related 与 /api/btf/funcidmap 始终对应启动时的种子。
+	rt.Register(http.MethodGet, "/api/btf/related", func(w http.ResponseWriter, r *http.Request) error {
+		return serveCachedJSON(w, relatedFuncPath)
+	})
+	rt.Register(http.MethodPost, "/api/btf/related", func(w http.ResponseWriter, r *http.Request) error {
+		q := r.URL.Query()
+		o, err := btfOptsFromQuery(opts, q)
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		var funcs []baserun.RelatedFunc
+		if q.Has("seeds") || q.Has("depth") {
+			funcs, err = o.RelatedFuncs()
+		} else {
+			funcs, err = o.ReadBTFandGetItsMember()
+		}
+		if err != nil {
+			return err
+		}
+		return server.WriteJSON(w, http.StatusOK, funcs)
+	})
+	// 按种子分别返回相关类型与函数，例如 ?seeds=sock,net_device；funcs=1 时省略类型。
+	rt.Register(http.MethodGet, "/api/btf/closure", func(w http.ResponseWriter, r *http.Request) error {
+		q := r.URL.Query()
+		o, err := btfOptsFromQuery(opts, q)
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		results, err := o.Related()
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		if q.Get("funcs") == "1" {
+			for i := range results {
+				results[i].Types = nil
+			}
+		}
+		return server.WriteJSON(w, http.StatusOK, results)
+	})
+
+	// BTF 查询，例如 ?op=find&q=tcp_v4_*&kind=func、?op=members&q=sock、?op=sig&q=ip_rcv、
+	// ?op=using&q=sk_buff、?op=field&q=sock.__sk_common.skc_daddr；find 加 regex=1 使用正则表达式。
+	// BTF 在第一次查询时解析，之后复用同一个 btf.Query 及其索引。
+	var btfMu sync.Mutex
+	var btfQuery *btf.Query
+	rt.Register(http.MethodGet, "/api/btf/query", func(w http.ResponseWriter, r *http.Request) error {
+		v := r.URL.Query()
+		kinds, err := parseKinds(v.Get("kind"))
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		btfMu.Lock()
+		if btfQuery == nil {
+			btfQuery, err = opts.Query()
+		}
+		q := btfQuery
+		btfMu.Unlock()
+		if err != nil {
+			return err
+		}
+		res, err := runBTFQuery(q, v.Get("op"), v.Get("q"), kinds, v.Get("regex") == "1")
+		if err != nil {
+			return server.Errorf(http.StatusBadRequest, "%v", err)
+		}
+		return server.WriteJSON(w, http.StatusOK, res)
+	})
+
+	rt.Register(http.MethodGet, "/api/btf/funcidmap", func(w http.ResponseWriter, r *http.Request) error {
+		return serveCachedJSON(w, funcIDMapPath)
+	})
+	rt.Register(http.MethodPost, "/api/btf/funcidmap", func(w http.ResponseWriter, r *http.Request) error {
+		if err := opts.TranslateJSON(); err != nil {
+			return err
+		}
+		return serveCachedJSON(w, funcIDMapPath)
+	})
+}
+
+// btfOptsFromQuery 以 serve 的 BTF 参数为默认值，用 ?seeds=、?depth= 覆盖种子类型与距离上限。
+// BTF 文件与模块目录只能在启动时指定。
+func btfOptsFromQuery(base *baserun.Options, q url.Values) (*baserun.Options, error) {
+	o := *base
+	if v := q.Get("seeds"); v != "" {
+		o.Seeds = splitList(v)
+	}
+	if v := q.Get("depth"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid depth %q", v)
+		}
+		o.Depth = d
+	}
+	return &o, nil
+}
+
+// maxDiffBaselines 限制 GET /api/sockets/diff 保存的基线数，超出时丢弃最久未用的。
+const maxDiffBaselines = 64
+
+type diffBaseline struct {
+	snap *socklist.Snapshot
+	used time.Time
+}
+
+func storeBaseline(baselines map[string]*diffBaseline, key string, snap *socklist.Snapshot) {
+	baselines[key] = &diffBaseline{snap: snap, used: time.Now()}
+	for len(baselines) > maxDiffBaselines {
+		var oldest string
+		for k, b := range baselines {
+			if oldest == "" || b.used.Before(baselines[oldest].used) {
+				oldest = k
+			}
+		}
+		delete(baselines, oldest)
+	}
+}
+
+// serveCachedJSON 原样返回 .cache 下已生成的 JSON 文件；文件不存在时返回 404。
+func serveCachedJSON(w http.ResponseWriter, path string) error {
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return server.Errorf(http.StatusNotFound, "%s not generated yet", path)
+	}
+	if err != nil {
+		return err
+	}
+	if !json.Valid(b) {
+		return server.Errorf(http.StatusInternalServerError, "%s is not valid JSON", path)
+	}
+	return server.WriteJSON(w, http.StatusOK, json.RawMessage(b))
+}
+
+// watchSSE 以 Server-Sent-Events 推送 Watch 的结果：首个事件为 "snapshot"，
+// 之后有变化时推送 "diff"，无变化时只发注释行保活；客户端断开即取消。
+func watchSSE(w http.ResponseWriter, r *http.Request, l *socklist.Lister, interval time.Duration) error {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return err
+	}
+
+	send := func(event string, v interface{}) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+
+	for ev := range l.Watch(r.Context(), interval) {
+		var err error
+		switch {
+		case ev.Err != nil:
+			err = send("error", map[string]string{"error": ev.Err.Error()})
+		case ev.Diff == nil:
+			err = send("snapshot", ev.Snapshot)
+		case ev.Diff.Empty():
+			if _, err = fmt.Fprint(w, ": keepalive\n\n"); err == nil {
+				err = rc.Flush()
+			}
+		default:
+			err = send("diff", ev.Diff)
+		}
+		if err != nil {
+			// 客户端已断开，响应头已经写出，不再返回 JSON 错误
+			return nil
+		}
+	}
+	return nil
+}
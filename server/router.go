@@ -0,0 +1,189 @@
+// Package server 提供一个可扩展的本地 HTTP JSON 服务：
+// 通过 Router.Register(method, path, handler) 注册路由，所有响应（包括错误）均为 JSON。
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HandlerFunc 是路由处理函数。返回的 error 会被统一转换为 JSON 错误响应。
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Middleware 包装 http.Handler，例如日志、统一的 JSON header。
+type Middleware func(http.Handler) http.Handler
+
+// HTTPError 携带 HTTP 状态码的错误，处理函数可以直接返回它。
+type HTTPError struct {
+	Status int
+	Err    error
+}
+
+func (e *HTTPError) Error() string { return e.Err.Error() }
+
+func (e *HTTPError) Unwrap() error { return e.Err }
+
+// Errorf 构造带状态码的错误。
+func Errorf(status int, format string, args ...interface{}) error {
+	return &HTTPError{Status: status, Err: fmt.Errorf(format, args...)}
+}
+
+// Router 把 (method, path) 映射到 HandlerFunc。可并发注册与访问。
+type Router struct {
+	mu          sync.RWMutex
+	routes      map[string]map[string]HandlerFunc // path -> method -> handler
+	middlewares []Middleware
+}
+
+// NewRouter 创建空路由表。
+func NewRouter() *Router {
+	return &Router{routes: make(map[string]map[string]HandlerFunc)}
+}
+
+// Register 注册一条路由；相同 method+path 重复注册时后者覆盖前者。
+func (rt *Router) Register(method, path string, h HandlerFunc) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	method = strings.ToUpper(method)
+	if rt.routes[path] == nil {
+		rt.routes[path] = make(map[string]HandlerFunc)
+	}
+	rt.routes[path][method] = h
+}
+
+// Use 追加中间件，先注册的在最外层。
+func (rt *Router) Use(m ...Middleware) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+	rt.middlewares = append(rt.middlewares, m...)
+}
+
+// Routes 返回已注册的 "METHOD path" 列表（已排序），用于索引页。
+func (rt *Router) Routes() []string {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	var out []string
+	for path, methods := range rt.routes {
+		for m := range methods {
+			out = append(out, m+" "+path)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Handler 返回套上全部中间件之后的 http.Handler。
+func (rt *Router) Handler() http.Handler {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+	var h http.Handler = http.HandlerFunc(rt.dispatch)
+	for i := len(rt.middlewares) - 1; i >= 0; i-- {
+		h = rt.middlewares[i](h)
+	}
+	return h
+}
+
+func (rt *Router) dispatch(w http.ResponseWriter, r *http.Request) {
+	rt.mu.RLock()
+	methods, ok := rt.routes[r.URL.Path]
+	var h HandlerFunc
+	if ok {
+		h = methods[r.Method]
+	}
+	rt.mu.RUnlock()
+
+	if !ok {
+		writeError(w, Errorf(http.StatusNotFound, "no route for %s", r.URL.Path))
+		return
+	}
+	if h == nil {
+		allowed := make([]string, 0, len(methods))
+		for m := range methods {
+			allowed = append(allowed, m)
+		}
+		sort.Strings(allowed)
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(w, Errorf(http.StatusMethodNotAllowed, "method %s not allowed on %s", r.Method, r.URL.Path))
+		return
+	}
+	if err := h(w, r); err != nil {
+		writeError(w, err)
+	}
+}
+
+// WriteJSON 以给定状态码写出 JSON。v 为 json.RawMessage 时原样写出。
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal response: %w", err)
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, err = w.Write(b)
+	return err
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var he *HTTPError
+	if errors.As(err, &he) {
+		status = he.Status
+	}
+	if werr := WriteJSON(w, status, map[string]interface{}{
+		"error":  err.Error(),
+		"status": status,
+	}); werr != nil {
+		log.Printf("write error response failed: %v", werr)
+	}
+}
+
+// JSONHeader 中间件：默认 Content-Type 为 application/json。
+func JSONHeader(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Logging 中间件：记录 method、path、状态码与耗时。
+func Logging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		log.Printf("%s %s %d %s", r.Method, r.URL.RequestURI(), sw.status, time.Since(start))
+	})
+}
+
+// Recover 中间件：把 handler 中的 panic 转换成 500 JSON 响应。
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if p := recover(); p != nil {
+				log.Printf("panic serving %s: %v", r.URL.Path, p)
+				writeError(w, Errorf(http.StatusInternalServerError, "internal error: %v", p))
+			}
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	sw.status = code
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap 让 http.ResponseController 能找到底层 ResponseWriter（例如 Flush）。
+func (sw *statusWriter) Unwrap() http.ResponseWriter { return sw.ResponseWriter }
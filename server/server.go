@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Server 是绑定了 Router 的 HTTP 服务。
+type Server struct {
+	Addr   string
+	Router *Router
+}
+
+// New 创建监听 addr 的 Server，并注册默认中间件（Recover、Logging、JSONHeader）。
+func New(addr string) *Server {
+	rt := NewRouter()
+	rt.Use(Recover, Logging, JSONHeader)
+	s := &Server{Addr: addr, Router: rt}
+	rt.Register(http.MethodGet, "/", func(w http.ResponseWriter, r *http.Request) error {
+		return WriteJSON(w, http.StatusOK, map[string]interface{}{"routes": rt.Routes()})
+	})
+	return s
+}
+
+// ListenAndServe 启动服务，ctx 取消时优雅关闭。
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	hs := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.Router.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("HTTP server listening on %s", s.Addr)
+		errCh <- hs.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := hs.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/Yinzhongkan399/GoServerPS/socklist"
+)
+
+// socketFlags 是与 socket 列表相关的子命令共用的参数。
+type socketFlags struct {
+	backend  string
+	procRoot string
+	netRoot  string
+	sysRoot  string
+	allNetns bool
+	filter   string
+}
+
+func (f *socketFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.backend, "backend", "proc", "socket backend: proc, netlink or auto")
+	fs.StringVar(&f.procRoot, "proc-root", "/proc", "procfs mount point, e.g. /host/proc")
+	fs.StringVar(&f.netRoot, "net-root", "", "directory holding tcp, udp, dev... (default <proc-root>/net)")
+	fs.StringVar(&f.sysRoot, "sys-root", "/sys", "sysfs mount point, used for /sys/class/net")
+	fs.BoolVar(&f.allNetns, "all-netns", false, "list sockets of every network namespace found under <proc-root>/*/ns/net")
+	fs.StringVar(&f.filter, "filter", "", `socket filter, e.g. "state established dport = :443" (trailing arguments are appended)`)
+}
+
+// parse 解析命令行；flag 之后剩余的参数按 ss 的习惯拼接为过滤表达式。
+func (f *socketFlags) parse(fs *flag.FlagSet, args []string) {
+	fs.Parse(args)
+	if rest := fs.Args(); len(rest) > 0 {
+		f.filter = strings.TrimSpace(f.filter + " " + strings.Join(rest, " "))
+	}
+}
+
+func (f *socketFlags) lister() (*socklist.Lister, error) {
+	backend, err := socklist.ParseBackend(f.backend)
+	if err != nil {
+		return nil, err
+	}
+	var filter *socklist.Filter
+	if f.filter != "" {
+		if filter, err = socklist.ParseFilter(f.filter); err != nil {
+			return nil, err
+		}
+	}
+	return &socklist.Lister{
+		Filter:        filter,
+		Backend:       backend,
+		ProcRoot:      f.procRoot,
+		NetRoot:       f.netRoot,
+		SysRoot:       f.sysRoot,
+		AllNamespaces: f.allNetns,
+	}, nil
+}
+
+// watchCmd 持续采集快照并打印变化，Ctrl-C 退出。
+func watchCmd(args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ExitOnError)
+	var sf socketFlags
+	sf.register(fs)
+	interval := fs.Duration("interval", 2*time.Second, "time between snapshots")
+	asJSON := fs.Bool("json", false, "print one JSON diff per line instead of text")
+	sf.parse(fs, args)
+
+	l, err := sf.lister()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	enc := json.NewEncoder(os.Stdout)
+	for ev := range l.Watch(ctx, *interval) {
+		switch {
+		case ev.Err != nil:
+			log.Printf("snapshot failed: %v", ev.Err)
+		case ev.Diff == nil:
+			log.Printf("watching %d sockets every %s", len(ev.Snapshot.Sockets), *interval)
+		case ev.Diff.Empty():
+		case *asJSON:
+			if err := enc.Encode(ev.Diff); err != nil {
+				return err
+			}
+		default:
+			printDiff(ev.Diff)
+		}
+	}
+	return nil
+}
+
+func printDiff(d *socklist.SnapshotDiff) {
+	ts := time.Unix(0, int64(d.To*1e9)).Format("15:04:05.000")
+	for _, s := range d.Added {
+		fmt.Printf("%s + %s\n", ts, describeSocket(s))
+	}
+	for _, s := range d.Removed {
+		fmt.Printf("%s - %s\n", ts, describeSocket(s))
+	}
+	for _, c := range d.Changed {
+		fmt.Printf("%s ~ %s (was %s)\n", ts, describeSocket(c.After), c.Before.StateName)
+	}
+}
+
+func describeSocket(s socklist.Socket) string {
+	out := fmt.Sprintf("%-7s %-6s %s", s.Protocol, s.StateName, s.Local())
+	if r := s.Remote(); r != "" {
+		out += " -> " + r
+	}
+	for _, p := range s.Processes {
+		out += fmt.Sprintf(" %s/%d", p.Comm, p.PID)
+	}
+	return out
+}
+
+func interfacesCmd(args []string) error {
+	fs := flag.NewFlagSet("interfaces", flag.ExitOnError)
+	var sf socketFlags
+	sf.register(fs)
+	fs.Parse(args)
+
+	l, err := sf.lister()
+	if err != nil {
+		return err
+	}
+	ifs, err := l.ListInterfaces()
+	if err != nil {
+		return err
+	}
+	return printJSON(ifs)
+}
+
+// listerFromQuery 以 serve 的命令行参数为默认值，用 HTTP 查询参数覆盖 backend、netns 与 filter。
+// proc-root/net-root 只能在启动时指定，不对 HTTP 客户端开放。
+func listerFromQuery(base socketFlags, q url.Values) (*socklist.Lister, error) {
+	f := flagsFromQuery(base, q)
+	return f.lister()
+}
+
+func flagsFromQuery(base socketFlags, q url.Values) socketFlags {
+	f := base
+	if v := q.Get("backend"); v != "" {
+		f.backend = v
+	}
+	if q.Get("netns") == "all" {
+		f.allNetns = true
+	}
+	if v := q.Get("filter"); v != "" {
+		f.filter = v
+	}
+	return f
+}
+
+// queryKey 返回决定 socket 列表内容的参数（backend、netns、规范化后的 filter），
+// 用于区分 GET /api/sockets/diff 的基线。
+func (f socketFlags) queryKey() string {
+	return fmt.Sprintf("%s|%t|%s", f.backend, f.allNetns, strings.Join(strings.Fields(f.filter), " "))
+}
+
+// listSockets 根据 l.AllNamespaces 返回 []Socket 或 []Namespace。
+func listSockets(l *socklist.Lister) (interface{}, error) {
+	if l.AllNamespaces {
+		return l.ListNamespaces()
+	}
+	return l.ListSockets()
+}
+
+func socketsCmd(args []string) error {
+	fs := flag.NewFlagSet("sockets", flag.ExitOnError)
+	var sf socketFlags
+	sf.register(fs)
+	legacy := fs.Bool("legacy", false, "print the legacy ListAll JSON shape")
+	snapshot := fs.Bool("snapshot", false, "print a timestamped snapshot (input for diff -prev)")
+	sf.parse(fs, args)
+
+	l, err := sf.lister()
+	if err != nil {
+		return err
+	}
+	if *legacy {
+		s, err := l.ListAll()
+		if err != nil {
+			return err
+		}
+		fmt.Println(s)
+		return nil
+	}
+	if *snapshot {
+		snap, err := l.Snapshot()
+		if err != nil {
+			return err
+		}
+		return printJSON(snap)
+	}
+	socks, err := listSockets(l)
+	if err != nil {
+		return err
+	}
+	return printJSON(socks)
+}
+
+// summaryCmd 打印 ss -s 风格的汇总，默认为表格。
+func summaryCmd(args []string) error {
+	fs := flag.NewFlagSet("summary", flag.ExitOnError)
+	var sf socketFlags
+	sf.register(fs)
+	top := fs.Int("top", 10, "number of peers, listeners and processes to show (0 = all)")
+	asJSON := fs.Bool("json", false, "print JSON instead of a table")
+	sf.parse(fs, args)
+
+	l, err := sf.lister()
+	if err != nil {
+		return err
+	}
+	snap, err := l.Snapshot()
+	if err != nil {
+		return err
+	}
+	sum := socklist.Summarize(snap, *top)
+	if *asJSON {
+		return printJSON(sum)
+	}
+	return sum.WriteTable(os.Stdout)
+}
+
+// topFromQuery 读取 ?top=N，缺省为 10。
+func topFromQuery(q url.Values) (int, error) {
+	v := q.Get("top")
+	if v == "" {
+		return 10, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid top %q", v)
+	}
+	return n, nil
+}
+
+// diffCmd 比较两次快照：默认间隔 -interval 采集两次；指定 -prev 时与保存的快照比较。
+func diffCmd(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	var sf socketFlags
+	sf.register(fs)
+	interval := fs.Duration("interval", 5*time.Second, "time between the two snapshots")
+	prevPath := fs.String("prev", "", "previous snapshot file written by `sockets -snapshot`")
+	sf.parse(fs, args)
+
+	l, err := sf.lister()
+	if err != nil {
+		return err
+	}
+	var prev *socklist.Snapshot
+	if *prevPath != "" {
+		b, err := os.ReadFile(*prevPath)
+		if err != nil {
+			return err
+		}
+		prev = &socklist.Snapshot{}
+		if err := json.Unmarshal(b, prev); err != nil {
+			return fmt.Errorf("parse %s: %w", *prevPath, err)
+		}
+	} else {
+		if prev, err = l.Snapshot(); err != nil {
+			return err
+		}
+		time.Sleep(*interval)
+	}
+	cur, err := l.Snapshot()
+	if err != nil {
+		return err
+	}
+	return printJSON(socklist.Diff(prev, cur))
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,182 @@
+//go:build linux
+// +build linux
+
+package socklist
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// familySource 描述 /proc/net 下一张非 inet 的 socket 表，file 相对于 Lister.NetRoot。
+type familySource struct {
+	file   string
+	family string
+	parse  func(fields []string, s *Socket) bool
+}
+
+var familySources = []familySource{
+	{"unix", "unix", parseUnixLine},
+	{"packet", "packet", parsePacketLine},
+	{"netlink", "netlink", parseNetlinkLine},
+}
+
+func readFamilyTable(path string, src familySource) ([]Socket, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	socks := []Socket{}
+	for i := 1; i < len(lines); i++ { // skip header line
+		fields := strings.Fields(lines[i])
+		s := Socket{Family: src.family, Protocol: src.family}
+		if !src.parse(fields, &s) {
+			continue
+		}
+		socks = append(socks, s)
+	}
+	return socks, nil
+}
+
+const soAcceptCon = 0x10000 // __SO_ACCEPTCON：处于 listen
+
+var sockTypeNames = map[int64]string{
+	1: "stream",
+	2: "dgram",
+	3: "raw",
+	4: "rdm",
+	5: "seqpacket",
+}
+
+// parseUnixLine 解析 /proc/net/unix：
+// Num RefCount Protocol Flags Type St Inode [Path]
+func parseUnixLine(f []string, s *Socket) bool {
+	if len(f) < 7 {
+		return false
+	}
+	num := strings.TrimSuffix(f[0], ":")
+	s.Sl = num
+	s.Pointer, _ = strconv.ParseUint(num, 16, 64)
+	s.RefCount, _ = strconv.ParseUint(f[1], 16, 64)
+	flags, _ := strconv.ParseUint(f[3], 16, 64)
+	typ, _ := strconv.ParseInt(f[4], 16, 64)
+	s.SockType = sockTypeName(typ)
+	st, _ := strconv.ParseInt(f[5], 16, 64)
+	s.State = int(st)
+	s.StateName = unixStateNames[st]
+	if s.StateName == "" {
+		s.StateName = "UNDEFINED"
+	}
+	if flags&soAcceptCon != 0 {
+		s.StateName = "LISTEN"
+	}
+	s.Inode, _ = strconv.ParseUint(f[6], 10, 64)
+	if len(f) > 7 {
+		s.Path = strings.Join(f[7:], " ")
+	}
+	return true
+}
+
+// parsePacketLine 解析 /proc/net/packet：
+// sk RefCnt Type Proto Iface R Rmem User Inode
+func parsePacketLine(f []string, s *Socket) bool {
+	if len(f) < 9 {
+		return false
+	}
+	s.Sl = f[0]
+	s.Pointer, _ = strconv.ParseUint(f[0], 16, 64)
+	s.RefCount, _ = strconv.ParseUint(f[1], 10, 64)
+	typ, _ := strconv.ParseInt(f[2], 10, 64)
+	s.SockType = sockTypeName(typ)
+	proto, _ := strconv.ParseUint(f[3], 16, 16)
+	s.SubProtocol = etherTypeName(proto)
+	s.Ifindex, _ = strconv.Atoi(f[4])
+	setUnconn(s)
+	s.RxQueue, _ = strconv.ParseUint(f[6], 10, 64)
+	uid, _ := strconv.ParseUint(f[7], 10, 32)
+	s.UID = uint32(uid)
+	s.Inode, _ = strconv.ParseUint(f[8], 10, 64)
+	return true
+}
+
+// parseNetlinkLine 解析 /proc/net/netlink：
+// sk Eth Pid Groups Rmem Wmem Dump Locks Drops Inode
+func parseNetlinkLine(f []string, s *Socket) bool {
+	if len(f) < 10 {
+		return false
+	}
+	s.Sl = f[0]
+	s.Pointer, _ = strconv.ParseUint(f[0], 16, 64)
+	proto, _ := strconv.ParseInt(f[1], 10, 64)
+	s.SubProtocol = netlinkProtoName(proto)
+	portID, _ := strconv.ParseUint(f[2], 10, 32)
+	s.PortID = uint32(portID)
+	setUnconn(s)
+	s.RxQueue, _ = strconv.ParseUint(f[4], 10, 64)
+	s.TxQueue, _ = strconv.ParseUint(f[5], 10, 64)
+	s.Inode, _ = strconv.ParseUint(f[9], 10, 64)
+	return true
+}
+
+// setUnconn 与 ss 一致：packet、netlink socket 没有连接状态，显示为 UNCONN（TCP_CLOSE）。
+func setUnconn(s *Socket) {
+	s.State = 7
+	s.StateName = "UNCONN"
+}
+
+func sockTypeName(t int64) string {
+	if name, ok := sockTypeNames[t]; ok {
+		return name
+	}
+	return strconv.FormatInt(t, 10)
+}
+
+var etherTypeNames = map[uint64]string{
+	0x0000: "none",
+	0x0003: "all",
+	0x0800: "ip",
+	0x0806: "arp",
+	0x86dd: "ipv6",
+	0x8100: "8021q",
+	0x88cc: "lldp",
+	0x888e: "pae",
+}
+
+func etherTypeName(p uint64) string {
+	if name, ok := etherTypeNames[p]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04x", p)
+}
+
+var netlinkProtoNames = map[int64]string{
+	0:  "route",
+	2:  "usersock",
+	3:  "firewall",
+	4:  "sock_diag",
+	5:  "nflog",
+	6:  "xfrm",
+	7:  "selinux",
+	8:  "iscsi",
+	9:  "audit",
+	10: "fib_lookup",
+	11: "connector",
+	12: "netfilter",
+	13: "ip6_fw",
+	14: "dnrtmsg",
+	15: "kobject_uevent",
+	16: "generic",
+	18: "scsitransport",
+	19: "ecryptfs",
+	20: "rdma",
+	21: "crypto",
+	22: "smc",
+}
+
+func netlinkProtoName(p int64) string {
+	if name, ok := netlinkProtoNames[p]; ok {
+		return name
+	}
+	return strconv.FormatInt(p, 10)
+}
@@ -0,0 +1,590 @@
+package socklist
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// Filter 是编译后的 socket 过滤表达式，语法接近 ss(8)：
+//
+//	state established dport = :443
+//	tcp and ( src 10.0.0.0/8 or dst [2001:db8::]/32 )
+//	udp and not uid = 0
+//	state listen state syn-recv          连续的 state 之间为“或”
+//
+// 支持的条件：
+//
+//	state NAME           状态名（不区分大小写，忽略 - 与 _），以及分组 all、connected、synchronized、bucket、big
+//	proto NAME / tcp udp raw icmp unix packet netlink
+//	family NAME / ipv4 ipv6 inet inet6 unix packet netlink
+//	sport OP PORT        PORT 写作 :443 或 443
+//	dport OP PORT
+//	src [OP] ADDR        ADDR 可为 IP、CIDR、IP:port、[IPv6]:port、:port
+//	dst [OP] ADDR
+//	uid OP N
+//	pid OP N             任一持有该 socket 的进程满足即可
+//	comm [OP] NAME       同上，按进程名
+//	inode OP N
+//
+// OP 为 = == != < > <= >= 或 eq ne lt gt le ge；条件之间用 and/&&（可省略）、or/||、not/! 与括号组合。
+type Filter struct {
+	expr string
+	root filterNode
+}
+
+// ParseFilter 编译过滤表达式。空表达式匹配全部 socket。
+func ParseFilter(expr string) (*Filter, error) {
+	toks, err := lexFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{toks: toks}
+	if len(toks) == 0 {
+		return &Filter{expr: expr, root: constNode(true)}, nil
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.eof() {
+		return nil, fmt.Errorf("filter: unexpected %q", p.peek())
+	}
+	return &Filter{expr: expr, root: root}, nil
+}
+
+// Match 判断 s 是否满足过滤条件。nil Filter 匹配全部。
+func (f *Filter) Match(s Socket) bool {
+	if f == nil {
+		return true
+	}
+	return f.root.match(s)
+}
+
+func (f *Filter) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.expr
+}
+
+// Apply 返回 socks 中满足条件的元素（原地过滤）。
+func (f *Filter) Apply(socks []Socket) []Socket {
+	if f == nil {
+		return socks
+	}
+	out := socks[:0]
+	for _, s := range socks {
+		if f.Match(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+/* --------- 语法树 --------- */
+
+type filterNode interface {
+	match(s Socket) bool
+}
+
+type constNode bool
+
+func (c constNode) match(Socket) bool { return bool(c) }
+
+type notNode struct{ x filterNode }
+
+func (n notNode) match(s Socket) bool { return !n.x.match(s) }
+
+type andNode struct{ l, r filterNode }
+
+func (n andNode) match(s Socket) bool { return n.l.match(s) && n.r.match(s) }
+
+type orNode struct{ l, r filterNode }
+
+func (n orNode) match(s Socket) bool { return n.l.match(s) || n.r.match(s) }
+
+type funcNode func(s Socket) bool
+
+func (f funcNode) match(s Socket) bool { return f(s) }
+
+/* --------- 词法 --------- */
+
+const filterOpChars = "=!<>&|"
+
+// filterOps 是运算符记号，按最长匹配切分："&&!" 为 "&&" 与 "!"，"!=" 为一个记号。
+var filterOps = []string{"&&", "||", "==", "!=", "<=", ">=", "=", "<", ">", "!"}
+
+func lexFilter(expr string) ([]string, error) {
+	var toks []string
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, string(c))
+			i++
+		case strings.IndexByte(filterOpChars, c) >= 0:
+			op := ""
+			for _, o := range filterOps {
+				if strings.HasPrefix(expr[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("filter: unexpected %q", c)
+			}
+			toks = append(toks, op)
+			i += len(op)
+		default:
+			j := i
+			for j < len(expr) && !strings.ContainsRune(" \t\n\r()"+filterOpChars, rune(expr[j])) {
+				j++
+			}
+			toks = append(toks, expr[i:j])
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+/* --------- 语法 --------- */
+
+type filterParser struct {
+	toks []string
+	pos  int
+}
+
+func (p *filterParser) eof() bool { return p.pos >= len(p.toks) }
+
+func (p *filterParser) peek() string {
+	if p.eof() {
+		return ""
+	}
+	return p.toks[p.pos]
+}
+
+func (p *filterParser) next() (string, error) {
+	if p.eof() {
+		return "", fmt.Errorf("filter: unexpected end of expression")
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	return t, nil
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for t := strings.ToLower(p.peek()); t == "or" || t == "||"; t = strings.ToLower(p.peek()) {
+		p.pos++
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orNode{l, r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := strings.ToLower(p.peek())
+		if p.eof() || t == ")" || t == "or" || t == "||" {
+			return l, nil
+		}
+		if t == "and" || t == "&&" {
+			p.pos++
+		}
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = andNode{l, r}
+	}
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	t, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(t) {
+	case "not", "!":
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{x}, nil
+	case "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t, err := p.next(); err != nil || t != ")" {
+			return nil, fmt.Errorf("filter: missing ')'")
+		}
+		return x, nil
+	}
+	return p.parsePrimary(strings.ToLower(t))
+}
+
+func (p *filterParser) parsePrimary(key string) (filterNode, error) {
+	switch key {
+	case "tcp", "udp", "raw", "icmp", "unix", "packet", "netlink":
+		return protoNode(key, "="), nil
+	case "ipv4", "ipv6", "inet", "inet6":
+		return familyNode(key, "=")
+	case "state":
+		return p.parseStates()
+	case "proto", "protocol":
+		op, v, err := p.parseOptOpValue()
+		if err != nil {
+			return nil, err
+		}
+		return protoNode(strings.ToLower(v), op), nil
+	case "family":
+		op, v, err := p.parseOptOpValue()
+		if err != nil {
+			return nil, err
+		}
+		return familyNode(strings.ToLower(v), op)
+	case "sport", "dport":
+		op, v, err := p.parseOpValue()
+		if err != nil {
+			return nil, err
+		}
+		port, err := strconv.ParseUint(strings.TrimPrefix(v, ":"), 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid port %q", v)
+		}
+		local := key == "sport"
+		return funcNode(func(s Socket) bool {
+			if !isInet(s) {
+				return false
+			}
+			got := s.RemotePort
+			if local {
+				got = s.LocalPort
+			}
+			return compareUint(uint64(got), op, port)
+		}), nil
+	case "src", "dst":
+		op, v, err := p.parseOptOpValue()
+		if err != nil {
+			return nil, err
+		}
+		return addrNode(key == "src", op, v)
+	case "uid", "inode":
+		op, v, err := p.parseOpValue()
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid %s %q", key, v)
+		}
+		if key == "uid" {
+			return funcNode(func(s Socket) bool { return compareUint(uint64(s.UID), op, n) }), nil
+		}
+		return funcNode(func(s Socket) bool { return compareUint(s.Inode, op, n) }), nil
+	case "pid":
+		op, v, err := p.parseOpValue()
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid pid %q", v)
+		}
+		return funcNode(func(s Socket) bool {
+			for _, proc := range s.Processes {
+				if compareUint(uint64(proc.PID), op, n) {
+					return true
+				}
+			}
+			return false
+		}), nil
+	case "comm":
+		op, v, err := p.parseOptOpValue()
+		if err != nil {
+			return nil, err
+		}
+		if op != "=" && op != "!=" {
+			return nil, fmt.Errorf("filter: comm only supports = and !=")
+		}
+		return funcNode(func(s Socket) bool {
+			found := false
+			for _, proc := range s.Processes {
+				if proc.Comm == v {
+					found = true
+					break
+				}
+			}
+			return found == (op == "=")
+		}), nil
+	}
+	return nil, fmt.Errorf("filter: unknown condition %q", key)
+}
+
+// parseStates 解析 "state A [state B ...]"，结果为各状态的“或”。
+func (p *filterParser) parseStates() (filterNode, error) {
+	var names []string
+	for {
+		v, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+		if !knownState(normState(v)) {
+			return nil, fmt.Errorf("filter: unknown state %q", v)
+		}
+		names = append(names, normState(v))
+		if strings.ToLower(p.peek()) != "state" {
+			break
+		}
+		p.pos++
+	}
+	return funcNode(func(s Socket) bool {
+		for _, n := range names {
+			if stateMatches(n, s) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func (p *filterParser) parseOpValue() (string, string, error) {
+	t, err := p.next()
+	if err != nil {
+		return "", "", err
+	}
+	op, ok := normOp(t)
+	if !ok {
+		return "", "", fmt.Errorf("filter: expected comparison operator, got %q", t)
+	}
+	v, err := p.next()
+	return op, v, err
+}
+
+// parseOptOpValue 允许省略运算符（默认为 =），如 "src 10.0.0.0/8"。
+func (p *filterParser) parseOptOpValue() (string, string, error) {
+	if _, ok := normOp(p.peek()); ok {
+		return p.parseOpValue()
+	}
+	v, err := p.next()
+	return "=", v, err
+}
+
+func normOp(t string) (string, bool) {
+	switch strings.ToLower(t) {
+	case "=", "==", "eq":
+		return "=", true
+	case "!=", "ne", "neq":
+		return "!=", true
+	case "<", "lt":
+		return "<", true
+	case ">", "gt":
+		return ">", true
+	case "<=", "le", "leq":
+		return "<=", true
+	case ">=", "ge", "geq":
+		return ">=", true
+	}
+	return "", false
+}
+
+func compareUint(a uint64, op string, b uint64) bool {
+	switch op {
+	case "=":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case ">":
+		return a > b
+	case "<=":
+		return a <= b
+	case ">=":
+		return a >= b
+	}
+	return false
+}
+
+func protoNode(name, op string) filterNode {
+	return funcNode(func(s Socket) bool { return (s.Protocol == name) == (op != "!=") })
+}
+
+func familyNode(name, op string) (filterNode, error) {
+	var fams []string
+	switch name {
+	case "ipv4":
+		fams = []string{"ipv4"}
+	case "ipv6", "inet6":
+		fams = []string{"ipv6"}
+	case "inet":
+		fams = []string{"ipv4", "ipv6"}
+	case "unix", "packet", "netlink":
+		fams = []string{name}
+	default:
+		return nil, fmt.Errorf("filter: unknown family %q", name)
+	}
+	return funcNode(func(s Socket) bool {
+		in := false
+		for _, f := range fams {
+			if s.Family == f {
+				in = true
+			}
+		}
+		return in == (op != "!=")
+	}), nil
+}
+
+func isInet(s Socket) bool {
+	return s.Family == "ipv4" || s.Family == "ipv6"
+}
+
+// addrNode 匹配本端（src）或对端（dst）地址。ADDR 可为：
+// "10.0.0.0/8"、"1.2.3.4"、"1.2.3.4:80"、"[::1]:22"、"[2001:db8::]/32"、":443"、"*"。
+func addrNode(local bool, op, v string) (filterNode, error) {
+	if op != "=" && op != "!=" {
+		return nil, fmt.Errorf("filter: src/dst only support = and !=")
+	}
+	host, portStr := v, ""
+	switch {
+	case strings.HasPrefix(v, "["):
+		end := strings.Index(v, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("filter: invalid address %q", v)
+		}
+		host = v[1:end]
+		rest := v[end+1:]
+		switch {
+		case strings.HasPrefix(rest, ":"):
+			portStr = rest[1:]
+		case strings.HasPrefix(rest, "/"):
+			host += rest
+		case rest != "":
+			return nil, fmt.Errorf("filter: invalid address %q", v)
+		}
+	case strings.Count(v, ":") == 1:
+		host, portStr, _ = strings.Cut(v, ":")
+	}
+
+	var prefix netip.Prefix
+	anyHost := host == "" || host == "*"
+	if !anyHost {
+		var err error
+		if strings.Contains(host, "/") {
+			prefix, err = netip.ParsePrefix(host)
+		} else {
+			var a netip.Addr
+			a, err = netip.ParseAddr(host)
+			prefix = netip.PrefixFrom(a, a.BitLen())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("filter: invalid address %q", v)
+		}
+		prefix = prefix.Masked()
+	}
+	anyPort := portStr == "" || portStr == "*"
+	var port uint64
+	if !anyPort {
+		var err error
+		if port, err = strconv.ParseUint(portStr, 10, 16); err != nil {
+			return nil, fmt.Errorf("filter: invalid port in %q", v)
+		}
+	}
+
+	return funcNode(func(s Socket) bool {
+		if !isInet(s) {
+			return false
+		}
+		ip, p := s.RemoteIP, s.RemotePort
+		if local {
+			ip, p = s.LocalIP, s.LocalPort
+		}
+		ok := anyPort || uint64(p) == port
+		if ok && !anyHost {
+			ok = ip.IsValid() && prefixContains(prefix, ip)
+		}
+		return ok == (op == "=")
+	}), nil
+}
+
+// prefixContains 让 IPv4 网段也能匹配 IPv4-mapped IPv6 地址（::ffff:a.b.c.d）。
+func prefixContains(p netip.Prefix, a netip.Addr) bool {
+	if p.Addr().Is4() {
+		a = a.Unmap()
+	}
+	return p.Contains(a)
+}
+
+/* --------- 状态 --------- */
+
+// normState 统一大小写与分隔符："FIN-WAIT-1" 与 "FIN_WAIT1" 都变成 "finwait1"。
+func normState(name string) string {
+	n := strings.ToLower(name)
+	n = strings.ReplaceAll(n, "-", "")
+	n = strings.ReplaceAll(n, "_", "")
+	switch n {
+	case "listening":
+		return "listen"
+	case "closed":
+		return "close"
+	case "estab":
+		return "established"
+	}
+	return n
+}
+
+var stateGroups = map[string]bool{"all": true, "connected": true, "synchronized": true, "bucket": true, "big": true}
+
+func knownState(n string) bool {
+	if stateGroups[n] {
+		return true
+	}
+	for _, names := range []map[int64]string{tcpStateNames, datagramStateNames, unixStateNames} {
+		for _, name := range names {
+			if normState(name) == n {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// stateMatches 判断状态名或分组是否覆盖 s。分组语义与 ss 相同：
+// connected 为除 listen、close 以外的全部状态；synchronized 再去掉 syn-sent；
+// bucket 为 syn-recv、new-syn-recv 与 time-wait；big 为除 bucket 以外的全部状态。
+// established 同时匹配状态为 CONNECTED 的非 TCP socket：已 connect 的 udp/raw/icmp 与已连接的 unix socket，
+// 与 ss 把它们显示为 ESTAB 一致。
+func stateMatches(n string, s Socket) bool {
+	cur := normState(s.StateName)
+	switch n {
+	case "all":
+		return true
+	case "connected":
+		return cur != "listen" && cur != "close" && cur != "unconn" && cur != "unconnected"
+	case "synchronized":
+		return stateMatches("connected", s) && cur != "synsent"
+	case "bucket":
+		return cur == "synrecv" || cur == "newsynrecv" || cur == "timewait"
+	case "big":
+		return !stateMatches("bucket", s)
+	case "established":
+		return cur == n || (s.Protocol != "tcp" && cur == "connected")
+	}
+	return cur == n
+}
@@ -0,0 +1,119 @@
+package socklist
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func inetSocket(proto, state, local, remote string) Socket {
+	l, r := netip.MustParseAddrPort(local), netip.MustParseAddrPort(remote)
+	s := Socket{Family: "ipv4", Protocol: proto, StateName: state}
+	if l.Addr().Is6() {
+		s.Family = "ipv6"
+	}
+	s.setEndpoints(l, r)
+	return s
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		err  string
+	}{
+		{"(tcp", "missing ')'"},
+		{"(sport = :22 or sport = :80", "missing ')'"},
+		{"tcp)", `unexpected ")"`},
+		{"not (sport = :22))", `unexpected ")"`},
+		{"state bogus", `unknown state "bogus"`},
+		{"sport = :http", `invalid port ":http"`},
+		{"sport :22", "expected comparison operator"},
+		{"src 10.0.0.0/33", "invalid address"},
+		{"dst [2001:db8::/32", "invalid address"},
+		{"src < 10.0.0.1", "only support = and !="},
+		{"family ipx", `unknown family "ipx"`},
+		{"color = red", `unknown condition "color"`},
+		{"tcp and", ""},
+		{"tcp & udp", `unexpected '&'`},
+		{"sport =< :22", `invalid port "<"`},
+	}
+	for _, tt := range tests {
+		_, err := ParseFilter(tt.expr)
+		if err == nil {
+			t.Errorf("ParseFilter(%q) succeeded, want error", tt.expr)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("ParseFilter(%q) error = %q, want it to contain %q", tt.expr, err, tt.err)
+		}
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	https := inetSocket("tcp", "ESTABLISHED", "192.168.1.5:51000", "93.184.216.34:443")
+	httpsListen := inetSocket("tcp", "LISTEN", "0.0.0.0:443", "0.0.0.0:0")
+	mapped := inetSocket("tcp", "ESTABLISHED", "[::ffff:10.1.2.3]:22", "[::ffff:192.168.1.9]:40000")
+	v6 := inetSocket("tcp", "ESTABLISHED", "[2001:db8::1]:8080", "[2001:db8:ffff::2]:33000")
+	v6Other := inetSocket("tcp", "ESTABLISHED", "[2001:db8::1]:8080", "[2001:db9::2]:33000")
+	ssh := inetSocket("tcp", "LISTEN", "0.0.0.0:22", "0.0.0.0:0")
+	web := inetSocket("tcp", "LISTEN", "[::]:80", "[::]:0")
+	dns := inetSocket("udp", "CONNECTED", "10.0.0.2:53000", "10.0.0.1:53")
+	unix := Socket{Family: "unix", Protocol: "unix", StateName: "CONNECTED", Path: "/run/sock"}
+	unixUnconn := Socket{Family: "unix", Protocol: "unix", StateName: "UNCONNECTED", Path: "@dgram"}
+	unixListen := Socket{Family: "unix", Protocol: "unix", StateName: "LISTEN", Path: "/run/sshd.sock"}
+
+	tests := []struct {
+		expr string
+		s    Socket
+		want bool
+	}{
+		{"", unix, true},
+		{"state established dport = :443", https, true},
+		{"state established dport = :443", httpsListen, false},
+		{"state established dport = :443", dns, false},
+		{"state established", dns, true},  // 已 connect 的 udp 也算 established
+		{"state established", unix, true}, // 已连接的 unix socket 同样算 established
+		{"state established", unixUnconn, false},
+		{"state established", unixListen, false},
+		{"state connected", unix, true},
+		{"state connected", unixUnconn, false},
+		{"state listen", unixListen, true},
+		{"unix state established", unix, true},
+		{"state listen state syn-recv", ssh, true},
+		{"state connected", ssh, false},
+		{"src 10.0.0.0/8", mapped, true},
+		{"src 10.1.2.3:22", mapped, true},
+		{"src 10.0.0.0/8", https, false},
+		{"dst 192.168.0.0/16", mapped, true},
+		{"dst [2001:db8::]/32", v6, true},
+		{"dst [2001:db8::]/32", v6Other, false},
+		{"dst [2001:db8::]/32", https, false},
+		{"src [2001:db8::1]:8080", v6, true},
+		{"src :8080", v6, true},
+		{"dst != [2001:db8::]/32", v6Other, true},
+		{"not (sport = :22 or sport = :80)", ssh, false},
+		{"not (sport = :22 or sport = :80)", web, false},
+		{"not (sport = :22 or sport = :80)", https, true},
+		{"not (sport = :22 or sport = :80)", unix, true},
+		{"sport = :22 or sport = :80", unix, false},
+		{"tcp and ( src 10.0.0.0/8 or dst [2001:db8::]/32 )", mapped, true},
+		{"tcp and ( src 10.0.0.0/8 or dst [2001:db8::]/32 )", dns, false},
+		{"udp && ! dport = 53", dns, false},
+		{"sport = :22&&!dport = :80", ssh, true},
+		{"sport = :22&&!dport = :0", ssh, false},
+		{"sport!=:22||!tcp", ssh, false},
+		{"!!tcp", ssh, true},
+		{"family inet sport > 1024", https, true},
+		{"ipv6 sport >= 80", web, true},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.expr)
+		if err != nil {
+			t.Errorf("ParseFilter(%q): %v", tt.expr, err)
+			continue
+		}
+		if got := f.Match(tt.s); got != tt.want {
+			t.Errorf("%q matching %s %s %s -> %s = %v, want %v", tt.expr, tt.s.Protocol, tt.s.StateName, tt.s.Local(), tt.s.Remote(), got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+//go:build linux
+// +build linux
+
+package socklist
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListInterfaces 导出：使用默认配置列出全部网卡。
+func ListInterfaces() ([]Interface, error) {
+	return (&Lister{}).ListInterfaces()
+}
+
+// ListInterfaces 读取 <NetRoot>/dev 的全部计数器，并从 <SysRoot>/class/net/<if> 补充
+// ifindex、MTU、MAC 与 operstate。地址只对当前 namespace 可靠，自定义 NetRoot 时不填写。
+func (l *Lister) ListInterfaces() ([]Interface, error) {
+	curTime := float64(time.Now().UnixNano()) / 1e9
+	ifs, err := readDevStats(filepath.Join(l.netRoot(), "dev"), curTime)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ifs {
+		l.fillSysfs(&ifs[i])
+		if !l.customNet() {
+			fillAddrs(&ifs[i])
+		}
+	}
+	return ifs, nil
+}
+
+// InterfacesByIndex 按 ifindex 建立索引，用于把 tcx 探针的 netifidx 转换为网卡名。
+func InterfacesByIndex(ifs []Interface) map[int]Interface {
+	m := make(map[int]Interface, len(ifs))
+	for _, ifc := range ifs {
+		if ifc.Index > 0 {
+			m[ifc.Index] = ifc
+		}
+	}
+	return m
+}
+
+// readDevStats 解析 /proc/net/dev（前两行为表头）。
+// 老内核中网卡名与第一个数字之间可能没有空格（"eth0:123"），因此先按 ':' 切分。
+func readDevStats(path string, curTime float64) ([]Interface, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	ifs := []Interface{}
+	for i := 2; i < len(lines); i++ {
+		name, rest, ok := strings.Cut(lines[i], ":")
+		if !ok {
+			continue
+		}
+		ifc := Interface{Time: curTime, Name: strings.TrimSpace(name)}
+		var c [16]uint64
+		for j, f := range strings.Fields(rest) {
+			if j >= len(c) {
+				break
+			}
+			c[j], _ = strconv.ParseUint(f, 10, 64)
+		}
+		ifc.RxBytes, ifc.RxPackets, ifc.RxErrs, ifc.RxDrop = c[0], c[1], c[2], c[3]
+		ifc.RxFifo, ifc.RxFrame, ifc.RxCompressed, ifc.RxMulticast = c[4], c[5], c[6], c[7]
+		ifc.TxBytes, ifc.TxPackets, ifc.TxErrs, ifc.TxDrop = c[8], c[9], c[10], c[11]
+		ifc.TxFifo, ifc.TxColls, ifc.TxCarrier, ifc.TxCompressed = c[12], c[13], c[14], c[15]
+		ifs = append(ifs, ifc)
+	}
+	return ifs, nil
+}
+
+func (l *Lister) fillSysfs(ifc *Interface) {
+	dir := filepath.Join(l.sysRoot(), "class", "net", ifc.Name)
+	read := func(name string) string {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(b))
+	}
+	ifc.Index, _ = strconv.Atoi(read("ifindex"))
+	ifc.MTU, _ = strconv.Atoi(read("mtu"))
+	ifc.MAC = read("address")
+	ifc.OperState = read("operstate")
+}
+
+func fillAddrs(ifc *Interface) {
+	ni, err := net.InterfaceByName(ifc.Name)
+	if err != nil {
+		return
+	}
+	addrs, err := ni.Addrs()
+	if err != nil {
+		return
+	}
+	for _, a := range addrs {
+		ifc.Addrs = append(ifc.Addrs, a.String())
+	}
+}
@@ -0,0 +1,183 @@
+//go:build linux
+// +build linux
+
+package socklist
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"syscall"
+)
+
+// inet_diag 协议常量（include/uapi/linux/sock_diag.h, inet_diag.h）。
+const (
+	sockDiagByFamily = 20
+	inetDiagInfo     = 2 // INET_DIAG_INFO，属性内容为 struct tcp_info
+
+	nlmsgHdrLen     = 16
+	inetDiagReqLen  = 56 // struct inet_diag_req_v2
+	inetDiagMsgLen  = 72 // struct inet_diag_msg
+	allStates       = 0xffffffff
+	netlinkRecvSize = 1 << 16
+)
+
+// struct tcp_info 中用到的字段偏移。老内核的 tcp_info 较短，读取前会检查长度。
+const (
+	tcpiRetransmitsOff   = 2
+	tcpiRTTOff           = 68
+	tcpiRTTVarOff        = 72
+	tcpiSndCwndOff       = 80
+	tcpiTotalRetransOff  = 100
+	tcpiBytesAckedOff    = 120
+	tcpiBytesReceivedOff = 128
+)
+
+// netlinkDump 通过 NETLINK_SOCK_DIAG 导出某个 family/protocol 的全部 socket。
+// TCP socket 会额外请求 INET_DIAG_INFO 以得到 tcp_info。
+func netlinkDump(src inetSource) ([]Socket, error) {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_DGRAM|syscall.SOCK_CLOEXEC, syscall.NETLINK_INET_DIAG)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(fd)
+
+	family := uint8(syscall.AF_INET)
+	if src.family == "ipv6" {
+		family = syscall.AF_INET6
+	}
+	var ext uint8
+	if src.ipproto == syscall.IPPROTO_TCP {
+		ext = 1 << (inetDiagInfo - 1)
+	}
+
+	req := make([]byte, nlmsgHdrLen+inetDiagReqLen)
+	binary.NativeEndian.PutUint32(req[0:4], uint32(len(req)))
+	binary.NativeEndian.PutUint16(req[4:6], sockDiagByFamily)
+	binary.NativeEndian.PutUint16(req[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_DUMP)
+	binary.NativeEndian.PutUint32(req[8:12], 1) // seq
+	body := req[nlmsgHdrLen:]
+	body[0] = family
+	body[1] = src.ipproto
+	body[2] = ext
+	binary.NativeEndian.PutUint32(body[4:8], allStates)
+
+	if err := syscall.Sendto(fd, req, 0, &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}); err != nil {
+		return nil, os.NewSyscallError("sendto", err)
+	}
+
+	socks := []Socket{}
+	buf := make([]byte, netlinkRecvSize)
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return nil, os.NewSyscallError("recvfrom", err)
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return nil, fmt.Errorf("parse netlink message: %w", err)
+		}
+		for _, m := range msgs {
+			switch m.Header.Type {
+			case syscall.NLMSG_DONE:
+				return socks, nil
+			case syscall.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := int32(binary.NativeEndian.Uint32(m.Data[0:4])); errno != 0 {
+						return nil, fmt.Errorf("sock_diag %s%s: %w", src.protocol, src.family, syscall.Errno(-errno))
+					}
+				}
+				return socks, nil
+			case sockDiagByFamily:
+				s, ok := parseInetDiagMsg(m.Data, src)
+				if ok {
+					socks = append(socks, s)
+				}
+			}
+		}
+	}
+}
+
+// parseInetDiagMsg 解析 struct inet_diag_msg 以及其后的 rtattr。
+func parseInetDiagMsg(b []byte, src inetSource) (Socket, bool) {
+	if len(b) < inetDiagMsgLen {
+		return Socket{}, false
+	}
+	s := Socket{
+		Family:   src.family,
+		Protocol: src.protocol,
+	}
+	s.State = int(b[1])
+	s.StateName = stateName(src.protocol, int64(b[1]))
+	s.Timer = int(b[2])
+	s.Retransmits = uint64(b[3])
+
+	// inet_diag_sockid：端口与地址均为网络字节序
+	s.setEndpoints(
+		netip.AddrPortFrom(diagAddr(b[8:24], src.family), binary.BigEndian.Uint16(b[4:6])),
+		netip.AddrPortFrom(diagAddr(b[24:40], src.family), binary.BigEndian.Uint16(b[6:8])),
+	)
+
+	s.TimerExpires = uint64(binary.NativeEndian.Uint32(b[52:56]))
+	s.RxQueue = uint64(binary.NativeEndian.Uint32(b[56:60]))
+	// LISTEN 状态下 idiag_wqueue 是 sk_max_ack_backlog 而不是队列长度，
+	// 与 /proc/net/tcp 一致地记为 0（idiag_rqueue 与 /proc 相同，是 sk_ack_backlog）
+	if s.StateName != "LISTEN" {
+		s.TxQueue = uint64(binary.NativeEndian.Uint32(b[60:64]))
+	}
+	s.UID = binary.NativeEndian.Uint32(b[64:68])
+	s.Inode = uint64(binary.NativeEndian.Uint32(b[68:72]))
+
+	attrs := b[inetDiagMsgLen:]
+	for len(attrs) >= 4 {
+		alen := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		atype := binary.NativeEndian.Uint16(attrs[2:4])
+		if alen < 4 || alen > len(attrs) {
+			break
+		}
+		if atype == inetDiagInfo {
+			s.TCPInfo = parseTCPInfo(attrs[4:alen])
+		}
+		next := (alen + 3) &^ 3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+	return s, true
+}
+
+func parseTCPInfo(b []byte) *TCPInfo {
+	if len(b) <= tcpiRetransmitsOff {
+		return nil
+	}
+	info := &TCPInfo{Retransmits: b[tcpiRetransmitsOff]}
+	u32 := func(off int) uint32 {
+		if off+4 > len(b) {
+			return 0
+		}
+		return binary.NativeEndian.Uint32(b[off : off+4])
+	}
+	u64 := func(off int) uint64 {
+		if off+8 > len(b) {
+			return 0
+		}
+		return binary.NativeEndian.Uint64(b[off : off+8])
+	}
+	info.RTT = u32(tcpiRTTOff)
+	info.RTTVar = u32(tcpiRTTVarOff)
+	info.SndCwnd = u32(tcpiSndCwndOff)
+	info.TotalRetrans = u32(tcpiTotalRetransOff)
+	info.BytesAcked = u64(tcpiBytesAckedOff)
+	info.BytesReceived = u64(tcpiBytesReceivedOff)
+	return info
+}
+
+// diagAddr 把 inet_diag_sockid 中的地址（网络字节序，IPv4 只占前 4 字节）转换为 netip.Addr。
+func diagAddr(b []byte, family string) netip.Addr {
+	if family == "ipv4" {
+		return netip.AddrFrom4([4]byte(b[:4]))
+	}
+	return netip.AddrFrom16([16]byte(b[:16]))
+}
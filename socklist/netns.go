@@ -0,0 +1,121 @@
+//go:build linux
+// +build linux
+
+package socklist
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ListNamespaces 通过 <ProcRoot>/*/ns/net 找出所有不同的 network namespace，
+// 再读取各自代表进程的 <ProcRoot>/<pid>/net 列出 socket。
+// 只能走 /proc（netlink 只看得到当前 namespace），因此 Backend 为 netlink 时报错。
+func (l *Lister) ListNamespaces() ([]Namespace, error) {
+	if l.Backend == BackendNetlink {
+		return nil, fmt.Errorf("netlink backend cannot enumerate other network namespaces; use proc or auto")
+	}
+	nss, err := discoverNamespaces(l.procRoot())
+	if err != nil {
+		return nil, err
+	}
+	owners, err := scanSocketOwners(l.procRoot())
+	if err != nil {
+		return nil, err
+	}
+	for i := range nss {
+		sub := &Lister{
+			Backend:  BackendProc,
+			ProcRoot: l.ProcRoot,
+			Filter:   l.Filter,
+			NetRoot:  filepath.Join(l.procRoot(), strconv.Itoa(nss[i].PID), "net"),
+		}
+		socks, err := sub.collect(owners)
+		if err != nil {
+			return nil, err
+		}
+		for j := range socks {
+			socks[j].NetNS = nss[i].Inode
+		}
+		nss[i].Sockets = socks
+	}
+	return nss, nil
+}
+
+// discoverNamespaces 返回按 inode 排序的 namespace 列表（尚未填 Sockets）。
+// 无权限读取 ns 链接的进程会被跳过。
+func discoverNamespaces(procRoot string) ([]Namespace, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	byInode := make(map[uint64]*Namespace)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		pidDir := filepath.Join(procRoot, e.Name())
+		link, err := os.Readlink(filepath.Join(pidDir, "ns", "net"))
+		if err != nil {
+			continue
+		}
+		inode, ok := nsInode(link)
+		if !ok {
+			continue
+		}
+		if ns, seen := byInode[inode]; seen && ns.PID <= pid {
+			continue
+		}
+		byInode[inode] = &Namespace{
+			Inode:  inode,
+			PID:    pid,
+			Comm:   readComm(pidDir),
+			Cgroup: readCgroup(pidDir),
+		}
+	}
+	nss := make([]Namespace, 0, len(byInode))
+	for _, ns := range byInode {
+		nss = append(nss, *ns)
+	}
+	sort.Slice(nss, func(i, j int) bool { return nss[i].Inode < nss[j].Inode })
+	return nss, nil
+}
+
+// nsInode 解析 "net:[4026531840]"。
+func nsInode(link string) (uint64, bool) {
+	if !strings.HasPrefix(link, "net:[") || !strings.HasSuffix(link, "]") {
+		return 0, false
+	}
+	inode, err := strconv.ParseUint(link[len("net:["):len(link)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return inode, true
+}
+
+// readCgroup 优先返回 cgroup v2 的路径（"0::/..."），否则返回第一条 v1 记录的路径。
+func readCgroup(pidDir string) string {
+	lines, err := readLines(filepath.Join(pidDir, "cgroup"))
+	if err != nil {
+		return ""
+	}
+	fallback := ""
+	for _, line := range lines {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			return parts[2]
+		}
+		if fallback == "" {
+			fallback = parts[2]
+		}
+	}
+	return fallback
+}
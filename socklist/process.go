@@ -0,0 +1,82 @@
+//go:build linux
+// +build linux
+
+package socklist
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// scanSocketOwners 遍历 /proc/<pid>/fd，找出形如 socket:[inode] 的符号链接，
+// 返回 inode -> 持有该 socket 的进程列表。没有权限读取的进程会被跳过。
+func scanSocketOwners(procRoot string) (map[uint64][]Process, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	owners := make(map[uint64][]Process)
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || !e.IsDir() {
+			continue
+		}
+		pidDir := filepath.Join(procRoot, e.Name())
+		fds, err := os.ReadDir(filepath.Join(pidDir, "fd"))
+		if err != nil {
+			continue
+		}
+		comm := ""
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(pidDir, "fd", fd.Name()))
+			if err != nil {
+				continue
+			}
+			inode, ok := socketInode(link)
+			if !ok {
+				continue
+			}
+			fdNum, err := strconv.Atoi(fd.Name())
+			if err != nil {
+				continue
+			}
+			if comm == "" {
+				comm = readComm(pidDir)
+			}
+			owners[inode] = append(owners[inode], Process{PID: pid, Comm: comm, FD: fdNum})
+		}
+	}
+	return owners, nil
+}
+
+// socketInode 解析 "socket:[12345]"。
+func socketInode(link string) (uint64, bool) {
+	if !strings.HasPrefix(link, "socket:[") || !strings.HasSuffix(link, "]") {
+		return 0, false
+	}
+	inode, err := strconv.ParseUint(link[len("socket:["):len(link)-1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return inode, true
+}
+
+func readComm(pidDir string) string {
+	b, err := os.ReadFile(filepath.Join(pidDir, "comm"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// attachOwners 把进程信息填入 socks。inode 为 0 的 socket（如 TIME_WAIT）没有属主。
+func attachOwners(socks []Socket, owners map[uint64][]Process) {
+	for i := range socks {
+		if socks[i].Inode == 0 {
+			continue
+		}
+		socks[i].Processes = owners[socks[i].Inode]
+	}
+}
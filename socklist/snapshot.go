@@ -0,0 +1,120 @@
+package socklist
+
+// Snapshot 是某一时刻的完整 socket 列表，Time 为采集时间（Unix 秒）。
+type Snapshot struct {
+	Time    float64  `json:"time"`
+	Sockets []Socket `json:"sockets"`
+}
+
+// SocketKey 标识一个 socket：协议、两端地址与 inode。
+type SocketKey struct {
+	Protocol string `json:"protocol"`
+	Family   string `json:"family"`
+	Local    string `json:"local"`
+	Remote   string `json:"remote"`
+	Inode    uint64 `json:"inode"`
+	NetNS    uint64 `json:"netns,omitempty"`
+}
+
+// Key 返回 s 的 SocketKey。
+func (s Socket) Key() SocketKey {
+	return SocketKey{
+		Protocol: s.Protocol,
+		Family:   s.Family,
+		Local:    s.Local(),
+		Remote:   s.Remote(),
+		Inode:    s.Inode,
+		NetNS:    s.NetNS,
+	}
+}
+
+// StateChange 是前后两次快照中同一个 socket 的状态变化。
+type StateChange struct {
+	Before Socket `json:"before"`
+	After  Socket `json:"after"`
+}
+
+// SnapshotDiff 是两次快照之间新增、消失以及状态变化的 socket。
+type SnapshotDiff struct {
+	From    float64       `json:"from"`
+	To      float64       `json:"to"`
+	Added   []Socket      `json:"added"`
+	Removed []Socket      `json:"removed"`
+	Changed []StateChange `json:"changed"`
+}
+
+// Empty 表示两次快照之间没有变化。
+func (d *SnapshotDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff 比较 prev 与 cur。prev 为 nil 时 cur 中的全部 socket 都算新增。
+//
+// socket 先按 SocketKey 配对；剩下未配对的再忽略 inode 按协议与两端地址配对一次，
+// 因为连接进入 TIME_WAIT 后内核会把 inode 清零，这种情况应当算作状态变化而不是删除+新增。
+func Diff(prev, cur *Snapshot) *SnapshotDiff {
+	d := &SnapshotDiff{Added: []Socket{}, Removed: []Socket{}, Changed: []StateChange{}}
+	if cur != nil {
+		d.To = cur.Time
+	}
+	if prev != nil {
+		d.From = prev.Time
+	}
+	var prevSocks, curSocks []Socket
+	if prev != nil {
+		prevSocks = prev.Sockets
+	}
+	if cur != nil {
+		curSocks = cur.Sockets
+	}
+
+	before := make(map[SocketKey][]int, len(prevSocks))
+	for i, s := range prevSocks {
+		k := s.Key()
+		before[k] = append(before[k], i)
+	}
+	matched := make([]bool, len(prevSocks))
+	var unmatched []Socket
+	for _, s := range curSocks {
+		k := s.Key()
+		if idx := before[k]; len(idx) > 0 {
+			before[k] = idx[1:]
+			matched[idx[0]] = true
+			d.addIfChanged(prevSocks[idx[0]], s)
+			continue
+		}
+		unmatched = append(unmatched, s)
+	}
+
+	loose := make(map[SocketKey][]int)
+	for i, s := range prevSocks {
+		if !matched[i] {
+			k := s.Key()
+			k.Inode = 0
+			loose[k] = append(loose[k], i)
+		}
+	}
+	for _, s := range unmatched {
+		k := s.Key()
+		k.Inode = 0
+		if idx := loose[k]; len(idx) > 0 {
+			loose[k] = idx[1:]
+			matched[idx[0]] = true
+			d.addIfChanged(prevSocks[idx[0]], s)
+			continue
+		}
+		d.Added = append(d.Added, s)
+	}
+	for i, s := range prevSocks {
+		if !matched[i] {
+			d.Removed = append(d.Removed, s)
+		}
+	}
+	return d
+}
+
+func (d *SnapshotDiff) addIfChanged(before, after Socket) {
+	if before.State != after.State || before.StateName != after.StateName {
+		d.Changed = append(d.Changed, StateChange{Before: before, After: after})
+	}
+}
@@ -0,0 +1,74 @@
+package socklist
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	sock := func(proto, state, local, remote string, inode uint64) Socket {
+		s := inetSocket(proto, state, local, remote)
+		s.Inode = inode
+		return s
+	}
+	est := sock("tcp", "ESTABLISHED", "192.168.1.5:51000", "93.184.216.34:443", 20002)
+	closeWait := sock("tcp", "CLOSE_WAIT", "192.168.1.5:51000", "93.184.216.34:443", 20002)
+	timeWait := sock("tcp", "TIME_WAIT", "192.168.1.5:51000", "93.184.216.34:443", 0)
+	listen := sock("tcp", "LISTEN", "0.0.0.0:22", "0.0.0.0:0", 20001)
+	relisten := sock("tcp", "LISTEN", "0.0.0.0:22", "0.0.0.0:0", 20101) // sshd 重启后的新 socket
+	udp := sock("udp", "CONNECTED", "192.168.1.5:51000", "93.184.216.34:443", 20003)
+	estOther := sock("tcp", "ESTABLISHED", "192.168.1.5:51000", "93.184.216.34:443", 20004)
+
+	tests := []struct {
+		name      string
+		prev, cur []Socket
+		added     []string
+		removed   []string
+		changed   []string
+	}{
+		{"empty", nil, nil, nil, nil, nil},
+		{"unchanged", []Socket{listen, est}, []Socket{est, listen}, nil, nil, nil},
+		{"added and removed", []Socket{listen}, []Socket{est}, []string{"tcp 192.168.1.5:51000 ESTABLISHED 20002"}, []string{"tcp 0.0.0.0:22 LISTEN 20001"}, nil},
+		{"state change", []Socket{est}, []Socket{closeWait}, nil, nil, []string{"tcp 192.168.1.5:51000 ESTABLISHED 20002 -> CLOSE_WAIT 20002"}},
+		// 进入 TIME_WAIT 后 inode 为 0，忽略 inode 配对
+		{"time_wait", []Socket{listen, est}, []Socket{listen, timeWait}, nil, nil, []string{"tcp 192.168.1.5:51000 ESTABLISHED 20002 -> TIME_WAIT 0"}},
+		{"time_wait gone", []Socket{timeWait}, nil, nil, []string{"tcp 192.168.1.5:51000 TIME_WAIT 0"}, nil},
+		// inode 变了但状态相同，不算变化
+		{"new inode same state", []Socket{listen}, []Socket{relisten}, nil, nil, nil},
+		// inode 相同的优先配对，另一个才算消失
+		{"exact before loose", []Socket{estOther, closeWait}, []Socket{est}, nil, []string{"tcp 192.168.1.5:51000 ESTABLISHED 20004"}, []string{"tcp 192.168.1.5:51000 CLOSE_WAIT 20002 -> ESTABLISHED 20002"}},
+		// 协议不同的不配对
+		{"protocol differs", []Socket{timeWait}, []Socket{udp}, []string{"udp 192.168.1.5:51000 CONNECTED 20003"}, []string{"tcp 192.168.1.5:51000 TIME_WAIT 0"}, nil},
+		{"duplicates", []Socket{listen, listen}, []Socket{listen}, nil, []string{"tcp 0.0.0.0:22 LISTEN 20001"}, nil},
+	}
+	str := func(s Socket) string { return fmt.Sprintf("%s %s %s %d", s.Protocol, s.Local(), s.StateName, s.Inode) }
+	for _, tt := range tests {
+		d := Diff(&Snapshot{Time: 1, Sockets: tt.prev}, &Snapshot{Time: 2, Sockets: tt.cur})
+		var added, removed, changed []string
+		for _, s := range d.Added {
+			added = append(added, str(s))
+		}
+		for _, s := range d.Removed {
+			removed = append(removed, str(s))
+		}
+		for _, c := range d.Changed {
+			changed = append(changed, fmt.Sprintf("%s -> %s %d", str(c.Before), c.After.StateName, c.After.Inode))
+		}
+		if !reflect.DeepEqual(added, tt.added) || !reflect.DeepEqual(removed, tt.removed) || !reflect.DeepEqual(changed, tt.changed) {
+			t.Errorf("%s: added %q removed %q changed %q, want %q %q %q", tt.name, added, removed, changed, tt.added, tt.removed, tt.changed)
+		}
+		if d.From != 1 || d.To != 2 {
+			t.Errorf("%s: From/To = %v/%v, want 1/2", tt.name, d.From, d.To)
+		}
+		if d.Empty() != (tt.added == nil && tt.removed == nil && tt.changed == nil) {
+			t.Errorf("%s: Empty() = %v", tt.name, d.Empty())
+		}
+	}
+
+	// prev 为 nil（第一次采集）时全部算新增
+	d := Diff(nil, &Snapshot{Time: 3, Sockets: []Socket{listen, est}})
+	if len(d.Added) != 2 || len(d.Removed) != 0 || len(d.Changed) != 0 || d.From != 0 || d.To != 3 {
+		t.Errorf("Diff(nil, cur) = %+v", d)
+	}
+}
@@ -0,0 +1,192 @@
+// Package socklist 列出本机的 socket 与网卡信息（读取 /proc/net）。
+package socklist
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+)
+
+// Socket 是 /proc/net/{tcp,udp,raw,icmp}[6] 中的一行，按列拆成带类型的字段。
+type Socket struct {
+	Sl         string     `json:"sl"`
+	Family     string     `json:"family"`   // "ipv4" / "ipv6" / "unix" / "packet" / "netlink"
+	Protocol   string     `json:"protocol"` // "tcp" / "udp" / "raw" / "icmp" / "unix" / "packet" / "netlink"
+	LocalIP    netip.Addr `json:"local_ip"`
+	LocalPort  uint16     `json:"local_port"`
+	RemoteIP   netip.Addr `json:"remote_ip"`
+	RemotePort uint16     `json:"remote_port"`
+	V4Mapped   bool       `json:"v4_mapped,omitempty"` // 任一端为 IPv4-mapped IPv6 地址（::ffff:a.b.c.d）
+	State      int        `json:"state"`
+	StateName  string     `json:"state_name"`
+
+	TxQueue      uint64 `json:"tx_queue"`
+	RxQueue      uint64 `json:"rx_queue"`
+	Timer        int    `json:"timer"`         // tr：0 无定时器，1 重传，2 keepalive，3 TIME_WAIT，4 零窗口探测
+	TimerExpires uint64 `json:"timer_expires"` // proc 后端为 tm->when（jiffies），netlink 后端为毫秒
+	Retransmits  uint64 `json:"retransmits"`
+	UID          uint32 `json:"uid"`
+	Timeout      uint64 `json:"timeout"`
+	Inode        uint64 `json:"inode"`
+	RefCount     uint64 `json:"refcount"`
+	Pointer      uint64 `json:"pointer"` // 内核 struct sock 地址（通常已被 kptr_restrict 打码）
+
+	// 非 inet family 特有的字段
+	Path        string `json:"path,omitempty"`         // unix：绑定路径，抽象地址以 "@" 开头
+	SockType    string `json:"sock_type,omitempty"`    // unix、packet："stream" / "dgram" / "seqpacket" / "raw"
+	SubProtocol string `json:"sub_protocol,omitempty"` // packet：以太网协议（"all"、"ip"、"0x88cc"…）；netlink：协议名（"route"、"generic"…）
+	Ifindex     int    `json:"ifindex,omitempty"`      // packet：绑定的网卡，0 表示全部
+	PortID      uint32 `json:"port_id,omitempty"`      // netlink：portid（通常等于进程 pid）
+
+	NetNS     uint64    `json:"netns,omitempty"` // 仅 ListNamespaces 填写
+	Processes []Process `json:"processes,omitempty"`
+	TCPInfo   *TCPInfo  `json:"tcp_info,omitempty"` // 仅 netlink 后端的 TCP socket 有
+}
+
+// TCPInfo 是 struct tcp_info 中常用的几个字段。
+type TCPInfo struct {
+	RTT           uint32 `json:"rtt_us"`
+	RTTVar        uint32 `json:"rttvar_us"`
+	SndCwnd       uint32 `json:"snd_cwnd"`
+	Retransmits   uint8  `json:"retransmits"` // 当前连续重传次数
+	TotalRetrans  uint32 `json:"total_retrans"`
+	BytesAcked    uint64 `json:"bytes_acked"`
+	BytesReceived uint64 `json:"bytes_received"`
+}
+
+// Process 是持有某个 socket 的进程及其 fd 编号。同一 socket 可被多个进程共享（fork、SCM_RIGHTS）。
+type Process struct {
+	PID  int    `json:"pid"`
+	Comm string `json:"comm"`
+	FD   int    `json:"fd"`
+}
+
+// Local 返回 "ip:port" 形式的本端地址，IPv6 写作 "[2001:db8::1]:443"（RFC 5952）。
+// unix 返回路径，packet 返回 "协议@ifindex"，netlink 返回 portid。
+func (s Socket) Local() string {
+	switch s.Family {
+	case "unix":
+		return s.Path
+	case "packet":
+		return s.SubProtocol + "@" + strconv.Itoa(s.Ifindex)
+	case "netlink":
+		return s.SubProtocol + ":" + strconv.FormatUint(uint64(s.PortID), 10)
+	}
+	return netip.AddrPortFrom(s.LocalIP, s.LocalPort).String()
+}
+
+// Remote 返回 "ip:port" 形式的对端地址，格式同 Local。非 inet socket 返回空串。
+func (s Socket) Remote() string {
+	if !s.RemoteIP.IsValid() {
+		return ""
+	}
+	return netip.AddrPortFrom(s.RemoteIP, s.RemotePort).String()
+}
+
+func (s *Socket) setEndpoints(local, remote netip.AddrPort) {
+	s.LocalIP, s.LocalPort = local.Addr(), local.Port()
+	s.RemoteIP, s.RemotePort = remote.Addr(), remote.Port()
+	s.V4Mapped = s.LocalIP.Is4In6() || s.RemoteIP.Is4In6()
+}
+
+// Interface 是 /proc/net/dev 中的一行（16 个收发计数器）加上 /sys/class/net 中的属性。
+type Interface struct {
+	Time      float64  `json:"time"`
+	Name      string   `json:"name"`
+	Index     int      `json:"ifindex"`
+	MTU       int      `json:"mtu"`
+	MAC       string   `json:"mac"`
+	OperState string   `json:"operstate"`
+	Addrs     []string `json:"addrs,omitempty"` // CIDR 形式
+
+	RxBytes      uint64 `json:"rx_bytes"`
+	RxPackets    uint64 `json:"rx_packets"`
+	RxErrs       uint64 `json:"rx_errs"`
+	RxDrop       uint64 `json:"rx_drop"`
+	RxFifo       uint64 `json:"rx_fifo"`
+	RxFrame      uint64 `json:"rx_frame"`
+	RxCompressed uint64 `json:"rx_compressed"`
+	RxMulticast  uint64 `json:"rx_multicast"`
+	TxBytes      uint64 `json:"tx_bytes"`
+	TxPackets    uint64 `json:"tx_packets"`
+	TxErrs       uint64 `json:"tx_errs"`
+	TxDrop       uint64 `json:"tx_drop"`
+	TxFifo       uint64 `json:"tx_fifo"`
+	TxColls      uint64 `json:"tx_colls"`
+	TxCarrier    uint64 `json:"tx_carrier"`
+	TxCompressed uint64 `json:"tx_compressed"`
+}
+
+// Namespace 是一个 network namespace 及其中的 socket。
+type Namespace struct {
+	Inode   uint64   `json:"inode"`            // /proc/<pid>/ns/net 的 inode
+	PID     int      `json:"pid"`              // 代表进程：该 namespace 中 PID 最小的进程
+	Comm    string   `json:"comm"`             // 代表进程的命令名
+	Cgroup  string   `json:"cgroup,omitempty"` // 代表进程的 cgroup 路径，容器内通常包含容器 ID
+	Sockets []Socket `json:"sockets"`
+}
+
+// Backend 选择 socket 的枚举方式。
+type Backend string
+
+const (
+	BackendProc    Backend = "proc"    // 解析 /proc/net 文本（默认）
+	BackendNetlink Backend = "netlink" // NETLINK_SOCK_DIAG；raw/icmp 仍读 /proc
+	BackendAuto    Backend = "auto"    // 优先 netlink，失败时回退到 /proc
+)
+
+// ParseBackend 把字符串转换为 Backend，空串视为 BackendProc。
+func ParseBackend(s string) (Backend, error) {
+	switch Backend(s) {
+	case "", BackendProc:
+		return BackendProc, nil
+	case BackendNetlink, BackendAuto:
+		return Backend(s), nil
+	}
+	return "", fmt.Errorf("unknown socket backend %q (want proc, netlink or auto)", s)
+}
+
+// Lister 保存一次枚举的配置；零值即默认行为。
+type Lister struct {
+	Backend Backend
+
+	// ProcRoot 是 procfs 挂载点，用于扫描 <ProcRoot>/<pid>/fd，默认 "/proc"。
+	// 在 sidecar 容器中可设为 "/host/proc"。
+	ProcRoot string
+	// NetRoot 是 tcp、udp、dev 等表所在目录，默认 <ProcRoot>/net。
+	// 设为 "/proc/<pid>/net" 即可查看该进程所在的 network namespace；
+	// 也可以指向保存下来的 fixture 目录。
+	NetRoot string
+	// SysRoot 是 sysfs 挂载点，用于读取 <SysRoot>/class/net/<if>，默认 "/sys"。
+	SysRoot string
+	// AllNamespaces 为 true 时 Snapshot 包含 ListNamespaces 找到的全部 namespace。
+	AllNamespaces bool
+	// Filter 非 nil 时只返回满足条件的 socket（见 ParseFilter）。
+	Filter *Filter
+}
+
+func (l *Lister) procRoot() string {
+	if l.ProcRoot == "" {
+		return "/proc"
+	}
+	return l.ProcRoot
+}
+
+func (l *Lister) sysRoot() string {
+	if l.SysRoot == "" {
+		return "/sys"
+	}
+	return l.SysRoot
+}
+
+func (l *Lister) netRoot() string {
+	if l.NetRoot == "" {
+		return l.procRoot() + "/net"
+	}
+	return l.NetRoot
+}
+
+// customNet 表示 NetRoot 指向的不是本进程的 /proc/net，此时 netlink 查询到的 namespace 不一致。
+func (l *Lister) customNet() bool {
+	return l.netRoot() != "/proc/net"
+}
@@ -0,0 +1,332 @@
+//go:build linux
+// +build linux
+
+package socklist
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// inetSource 描述一个 /proc/net 下的 inet socket 表，file 相对于 Lister.NetRoot。
+// ipproto 非 0 时该表也可以通过 netlink sock_diag 获取。
+type inetSource struct {
+	file     string
+	protocol string
+	family   string
+	ipproto  uint8
+}
+
+// 顺序与原 Python ListAll 一致：tcp、udp、raw、icmp。
+var inetSources = []inetSource{
+	{"tcp", "tcp", "ipv4", syscall.IPPROTO_TCP},
+	{"tcp6", "tcp", "ipv6", syscall.IPPROTO_TCP},
+	{"udp", "udp", "ipv4", syscall.IPPROTO_UDP},
+	{"udp6", "udp", "ipv6", syscall.IPPROTO_UDP},
+	{"raw", "raw", "ipv4", 0},
+	{"raw6", "raw", "ipv6", 0},
+	{"icmp", "icmp", "ipv4", 0},
+	{"icmp6", "icmp", "ipv6", 0},
+}
+
+// ListSockets 导出：使用默认配置（/proc 后端）列出全部 socket。
+func ListSockets() ([]Socket, error) {
+	return (&Lister{}).ListSockets()
+}
+
+// ListAll 导出：返回与原 Python ListAll 等价的 JSON 字符串（以及可能的错误）。
+// 每行是位置数组 [time, sl, "ip:port", "ip:port", "0A(LISTEN)", ...]，保留给旧的调用方；
+// 新代码请使用 ListSockets。
+func ListAll() (string, error) {
+	return (&Lister{}).ListAll()
+}
+
+// ListSockets 返回全部 socket（inet 以及 unix、packet、netlink），并通过 /proc/*/fd 解析出持有它们的进程。
+// 不存在的表（例如未启用 IPv6）会被跳过。
+func (l *Lister) ListSockets() ([]Socket, error) {
+	if err := l.check(); err != nil {
+		return nil, err
+	}
+	owners, err := scanSocketOwners(l.procRoot())
+	if err != nil {
+		return nil, err
+	}
+	return l.collect(owners)
+}
+
+// Snapshot 采集一次完整的 socket 列表并打上时间戳。
+func (l *Lister) Snapshot() (*Snapshot, error) {
+	curTime := float64(time.Now().UnixNano()) / 1e9
+	if !l.AllNamespaces {
+		socks, err := l.ListSockets()
+		if err != nil {
+			return nil, err
+		}
+		return &Snapshot{Time: curTime, Sockets: socks}, nil
+	}
+	nss, err := l.ListNamespaces()
+	if err != nil {
+		return nil, err
+	}
+	snap := &Snapshot{Time: curTime, Sockets: []Socket{}}
+	for _, ns := range nss {
+		snap.Sockets = append(snap.Sockets, ns.Sockets...)
+	}
+	return snap, nil
+}
+
+// collect 读取 NetRoot 下的全部表并填入进程信息。
+func (l *Lister) collect(owners map[uint64][]Process) ([]Socket, error) {
+	tables, err := l.readTables()
+	if err != nil {
+		return nil, err
+	}
+	all := []Socket{}
+	for _, t := range tables {
+		all = append(all, t.socks...)
+	}
+	attachOwners(all, owners)
+	return l.Filter.Apply(all), nil
+}
+
+// ListAll 同包级 ListAll，但使用 l 的配置。
+func (l *Lister) ListAll() (string, error) {
+	if err := l.check(); err != nil {
+		return "", err
+	}
+	curTime := float64(time.Now().UnixNano()) / 1e9
+
+	total := make(map[string][][]interface{})
+
+	if err := l.getDevInfo(total, curTime); err != nil {
+		return "", err
+	}
+	owners, err := scanSocketOwners(l.procRoot())
+	if err != nil {
+		return "", err
+	}
+	tables, err := l.readTables()
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tables {
+		attachOwners(t.socks, owners)
+		data := [][]interface{}{}
+		for _, s := range l.Filter.Apply(t.socks) {
+			data = append(data, legacyRow(s, curTime))
+		}
+		total[t.key] = data
+	}
+
+	b, err := json.Marshal(total)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// table 是一张 /proc/net 表的解析结果，key 即旧版 ListAll 的 JSON key（如 "tcpipv4"、"unix"）。
+type table struct {
+	key   string
+	socks []Socket
+}
+
+// readTables 依次读取 inet 表以及 unix、packet、netlink 表。不存在的表被跳过，
+// 只有显式选择 netlink 后端且 sock_diag 失败时才返回错误。
+func (l *Lister) readTables() ([]table, error) {
+	var tables []table
+	for _, src := range inetSources {
+		socks, err := l.readTable(src)
+		if err != nil {
+			if l.Backend == BackendNetlink && src.ipproto != 0 {
+				return nil, err
+			}
+			continue
+		}
+		tables = append(tables, table{src.protocol + src.family, socks})
+	}
+	for _, src := range familySources {
+		socks, err := readFamilyTable(filepath.Join(l.netRoot(), src.file), src)
+		if err != nil {
+			continue
+		}
+		tables = append(tables, table{src.family, socks})
+	}
+	return tables, nil
+}
+
+// readTable 按 Backend 读取一张表。netlink 不支持的协议（raw、icmp）总是读 /proc；
+// BackendAuto 下 netlink 出错（老内核、无权限）时同样回退到 /proc。
+// 自定义 NetRoot 时 netlink 看到的是另一个 namespace，BackendAuto 直接使用 /proc。
+func (l *Lister) readTable(src inetSource) ([]Socket, error) {
+	useNetlink := l.Backend == BackendNetlink || (l.Backend == BackendAuto && !l.customNet())
+	if src.ipproto != 0 && useNetlink {
+		socks, err := netlinkDump(src)
+		if err == nil || l.Backend == BackendNetlink {
+			return socks, err
+		}
+	}
+	return readInetTable(filepath.Join(l.netRoot(), src.file), src)
+}
+
+func (l *Lister) check() error {
+	if l.Backend == BackendNetlink && l.customNet() {
+		return fmt.Errorf("netlink backend only sees the current network namespace; use proc or auto with NetRoot %s", l.netRoot())
+	}
+	return nil
+}
+
+/* --------- 非导出辅助函数（与 Python 对应） --------- */
+
+// legacyRow 前 5 列与原 Python 版本一致，其余列追加在后面，不影响按下标取值的旧调用方。
+func legacyRow(s Socket, curTime float64) []interface{} {
+	return []interface{}{curTime, s.Sl, s.Local(), s.Remote(), tranStateIntoStr(s),
+		s.TxQueue, s.RxQueue, s.Timer, s.TimerExpires, s.Retransmits,
+		s.UID, s.Timeout, s.Inode, s.RefCount, fmt.Sprintf("%016x", s.Pointer), processesOrEmpty(s.Processes)}
+}
+
+func processesOrEmpty(p []Process) []Process {
+	if p == nil {
+		return []Process{}
+	}
+	return p
+}
+
+func readInetTable(path string, src inetSource) ([]Socket, error) {
+	lines, err := readLines(path)
+	if err != nil {
+		return nil, err
+	}
+	socks := []Socket{}
+	for i := 1; i < len(lines); i++ { // skip header line
+		fields := strings.Fields(lines[i])
+		if len(fields) < 4 {
+			continue
+		}
+		local, err := parseProcAddr(fields[1])
+		if err != nil {
+			continue
+		}
+		remote, err := parseProcAddr(fields[2])
+		if err != nil {
+			continue
+		}
+		s := Socket{
+			Sl:       strings.TrimSuffix(fields[0], ":"),
+			Family:   src.family,
+			Protocol: src.protocol,
+		}
+		s.setEndpoints(local, remote)
+		stateVal, _ := strconv.ParseInt(fields[3], 16, 64)
+		s.State = int(stateVal)
+		s.StateName = stateName(src.protocol, stateVal)
+		parseExtraColumns(&s, fields[4:])
+		socks = append(socks, s)
+	}
+	return socks, nil
+}
+
+// parseExtraColumns 解析 st 之后的列：
+// tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode ref pointer ...
+// 缺失的列保持零值（老内核或截断的行）。
+func parseExtraColumns(s *Socket, fields []string) {
+	col := func(i int) string {
+		if i < len(fields) {
+			return fields[i]
+		}
+		return ""
+	}
+	s.TxQueue, s.RxQueue = parseHexPair(col(0))
+	timer, expires := parseHexPair(col(1))
+	s.Timer, s.TimerExpires = int(timer), expires
+	s.Retransmits, _ = strconv.ParseUint(col(2), 16, 64)
+	uid, _ := strconv.ParseUint(col(3), 10, 32)
+	s.UID = uint32(uid)
+	s.Timeout, _ = strconv.ParseUint(col(4), 10, 64)
+	s.Inode, _ = strconv.ParseUint(col(5), 10, 64)
+	s.RefCount, _ = strconv.ParseUint(col(6), 10, 64)
+	s.Pointer, _ = strconv.ParseUint(col(7), 16, 64)
+}
+
+// parseHexPair 解析 "0000000A:00000000" 形式的两段十六进制数。
+func parseHexPair(field string) (uint64, uint64) {
+	a, b, _ := strings.Cut(field, ":")
+	x, _ := strconv.ParseUint(a, 16, 64)
+	y, _ := strconv.ParseUint(b, 16, 64)
+	return x, y
+}
+
+// parseProcAddr 解析 /proc/net 中 "0100007F:0016" 形式的地址。
+// 内核用 %08X 按主机字节序打印每个 32 位字（IPv4 一个字，IPv6 四个字），
+// 因此每个字要按 NativeEndian 写回才能得到网络字节序的地址；在 x86 上直接按十六进制
+// 文本切分会得到字节颠倒的 IPv6 地址。
+func parseProcAddr(input string) (netip.AddrPort, error) {
+	iphex, porthex, ok := strings.Cut(input, ":")
+	if !ok || (len(iphex) != 8 && len(iphex) != 32) {
+		return netip.AddrPort{}, fmt.Errorf("invalid /proc/net address %q", input)
+	}
+	raw := make([]byte, len(iphex)/2)
+	for i := 0; i < len(iphex); i += 8 {
+		word, err := strconv.ParseUint(iphex[i:i+8], 16, 32)
+		if err != nil {
+			return netip.AddrPort{}, fmt.Errorf("invalid /proc/net address %q", input)
+		}
+		binary.NativeEndian.PutUint32(raw[i/2:], uint32(word))
+	}
+	port, err := strconv.ParseUint(porthex, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("invalid /proc/net port %q", input)
+	}
+	addr, _ := netip.AddrFromSlice(raw)
+	return netip.AddrPortFrom(addr, uint16(port)), nil
+}
+
+// tranStateIntoStr 返回旧版 "0A(LISTEN)" 形式的状态字符串。
+func tranStateIntoStr(s Socket) string {
+	return fmt.Sprintf("%02X(%s)", s.State, s.StateName)
+}
+
+func readLines(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	var lines []string
+	for sc.Scan() {
+		lines = append(lines, sc.Text())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// getDevInfo 旧版 "dev" 行为 [time, ifname]，其后追加
+// ifindex, mtu, mac, operstate 以及 rx 8 个、tx 8 个计数器。
+func (l *Lister) getDevInfo(total map[string][][]interface{}, curTime float64) error {
+	ifs, err := readDevStats(filepath.Join(l.netRoot(), "dev"), curTime)
+	if err != nil {
+		return err
+	}
+	data := [][]interface{}{}
+	for i := range ifs {
+		ifc := &ifs[i]
+		l.fillSysfs(ifc)
+		data = append(data, []interface{}{curTime, ifc.Name, ifc.Index, ifc.MTU, ifc.MAC, ifc.OperState,
+			ifc.RxBytes, ifc.RxPackets, ifc.RxErrs, ifc.RxDrop, ifc.RxFifo, ifc.RxFrame, ifc.RxCompressed, ifc.RxMulticast,
+			ifc.TxBytes, ifc.TxPackets, ifc.TxErrs, ifc.TxDrop, ifc.TxFifo, ifc.TxColls, ifc.TxCarrier, ifc.TxCompressed})
+	}
+	total["dev"] = data
+	return nil
+}
@@ -0,0 +1,163 @@
+//go:build linux
+// +build linux
+
+package socklist
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"testing"
+)
+
+// skipBigEndian：/proc/net 按主机字节序打印地址，testdata 与下面的输入都取自小端机器。
+func skipBigEndian(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("fixtures are little-endian")
+	}
+}
+
+// fixtureLister 读取 testdata 中记录的 /proc 与 /sys。
+func fixtureLister(t *testing.T) *Lister {
+	skipBigEndian(t)
+	return &Lister{ProcRoot: "testdata/proc", NetRoot: "testdata/proc/net", SysRoot: "testdata/sys"}
+}
+
+func TestListerListSockets(t *testing.T) {
+	socks, err := fixtureLister(t).ListSockets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sshd := []Process{{PID: 1234, Comm: "sshd", FD: 3}}
+	tests := []struct {
+		family, protocol, local, remote, state string
+		inode                                  uint64
+		procs                                  []Process
+	}{
+		{"ipv4", "tcp", "0.0.0.0:22", "0.0.0.0:0", "LISTEN", 20001, sshd},
+		{"ipv4", "tcp", "192.168.1.5:51000", "93.184.216.34:443", "ESTABLISHED", 20002, []Process{{PID: 4321, Comm: "curl", FD: 3}}},
+		{"ipv4", "tcp", "127.0.0.1:8080", "127.0.0.1:54321", "TIME_WAIT", 0, nil},
+		{"ipv6", "tcp", "[::]:80", "[::]:0", "LISTEN", 20003, nil},
+		{"ipv6", "tcp", "[::ffff:10.1.2.3]:22", "[::ffff:192.168.1.9]:40000", "ESTABLISHED", 20004, nil},
+		{"ipv4", "udp", "10.0.0.2:53000", "10.0.0.1:53", "CONNECTED", 20005, nil},
+		{"ipv4", "udp", "0.0.0.0:68", "0.0.0.0:0", "UNCONN", 20006, nil},
+		{"unix", "unix", "/run/sshd.sock", "", "LISTEN", 20007, []Process{{PID: 1234, Comm: "sshd", FD: 4}}},
+		{"unix", "unix", "", "", "CONNECTED", 20008, nil},
+		{"unix", "unix", "@abstract", "", "UNCONNECTED", 20009, nil},
+		{"packet", "packet", "all@2", "", "UNCONN", 20010, nil},
+		{"netlink", "netlink", "route:1234", "", "UNCONN", 20011, []Process{{PID: 1234, Comm: "sshd", FD: 5}}},
+		{"netlink", "netlink", "generic:0", "", "UNCONN", 20012, nil},
+	}
+	if len(socks) != len(tests) {
+		t.Fatalf("ListSockets returned %d sockets, want %d", len(socks), len(tests))
+	}
+	for i, tt := range tests {
+		s := socks[i]
+		got := []interface{}{s.Family, s.Protocol, s.Local(), s.Remote(), s.StateName, s.Inode}
+		want := []interface{}{tt.family, tt.protocol, tt.local, tt.remote, tt.state, tt.inode}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("socket %d = %v, want %v", i, got, want)
+		}
+		if !reflect.DeepEqual(s.Processes, tt.procs) {
+			t.Errorf("socket %d (%s) processes = %v, want %v", i, s.Local(), s.Processes, tt.procs)
+		}
+	}
+
+	est := socks[1]
+	if est.TxQueue != 0x10 || est.Timer != 2 || est.TimerExpires != 0xc8 || est.UID != 1000 || est.RefCount != 2 {
+		t.Errorf("established tcp columns = tx %d timer %d expires %d uid %d ref %d", est.TxQueue, est.Timer, est.TimerExpires, est.UID, est.RefCount)
+	}
+	if !socks[4].V4Mapped || socks[3].V4Mapped {
+		t.Errorf("V4Mapped = %v, %v, want true for the mapped socket only", socks[4].V4Mapped, socks[3].V4Mapped)
+	}
+	if socks[6].RxQueue != 0x340 {
+		t.Errorf("udp rx_queue = %d, want %d", socks[6].RxQueue, 0x340)
+	}
+	if s := socks[10]; s.SockType != "raw" || s.Ifindex != 2 {
+		t.Errorf("packet socket type %q ifindex %d, want raw on 2", s.SockType, s.Ifindex)
+	}
+}
+
+func TestListerFilter(t *testing.T) {
+	l := fixtureLister(t)
+	var err error
+	if l.Filter, err = ParseFilter("state listen"); err != nil {
+		t.Fatal(err)
+	}
+	socks, err := l.ListSockets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range socks {
+		got = append(got, s.Local())
+	}
+	want := []string{"0.0.0.0:22", "[::]:80", "/run/sshd.sock"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("state listen = %v, want %v", got, want)
+	}
+}
+
+func TestListerListAll(t *testing.T) {
+	out, err := fixtureLister(t).ListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var total map[string][][]interface{}
+	if err := json.Unmarshal([]byte(out), &total); err != nil {
+		t.Fatal(err)
+	}
+	counts := map[string]int{}
+	for k, rows := range total {
+		counts[k] = len(rows)
+	}
+	want := map[string]int{"dev": 2, "tcpipv4": 3, "tcpipv6": 2, "udpipv4": 2, "unix": 3, "packet": 1, "netlink": 2}
+	if !reflect.DeepEqual(counts, want) {
+		t.Fatalf("ListAll tables = %v, want %v", counts, want)
+	}
+
+	// 旧格式：[time, sl, local, remote, "0A(LISTEN)", ...]
+	row := total["tcpipv4"][0]
+	if got := row[1:5]; !reflect.DeepEqual(got, []interface{}{"0", "0.0.0.0:22", "0.0.0.0:0", "0A(LISTEN)"}) {
+		t.Errorf("tcpipv4 row = %v", got)
+	}
+	// [time, ifname, ifindex, mtu, mac, operstate, rx 8 个, tx 8 个]
+	eth0 := total["dev"][1]
+	if got := eth0[1:8]; !reflect.DeepEqual(got, []interface{}{"eth0", 2.0, 1500.0, "52:54:00:12:34:56", "up", 1234567.0, 2345.0}) {
+		t.Errorf("dev eth0 row = %v", got)
+	}
+	if got := eth0[13]; got != 17.0 {
+		t.Errorf("eth0 rx_multicast = %v, want 17", got)
+	}
+}
+
+func TestParseProcAddr(t *testing.T) {
+	skipBigEndian(t)
+	tests := []struct {
+		in   string
+		want netip.AddrPort
+	}{
+		{"0100007F:0016", netip.MustParseAddrPort("127.0.0.1:22")},
+		{"00000000:0000", netip.MustParseAddrPort("0.0.0.0:0")},
+		{"0000000000000000FFFF00000100007F:0016", netip.MustParseAddrPort("[::ffff:127.0.0.1]:22")},
+		{"00000000000000000000000001000000:0035", netip.MustParseAddrPort("[::1]:53")},
+		{"B80D0120000000000000000001000000:01BB", netip.MustParseAddrPort("[2001:db8::1]:443")},
+	}
+	for _, tt := range tests {
+		got, err := parseProcAddr(tt.in)
+		if err != nil {
+			t.Errorf("parseProcAddr(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseProcAddr(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+
+	for _, in := range []string{"", "0100007F", "0100007F:", "0100007:0016", "0100007G:0016", "0100007F:10000"} {
+		if got, err := parseProcAddr(in); err == nil {
+			t.Errorf("parseProcAddr(%q) = %s, want error", in, got)
+		}
+	}
+}
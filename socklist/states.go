@@ -0,0 +1,49 @@
+package socklist
+
+// 状态表与 /proc/net 的解析无关，过滤与汇总在其他平台上也要用到，因此不加 linux 构建标签。
+
+// TCP 状态（include/net/tcp_states.h）。
+var tcpStateNames = map[int64]string{
+	1:  "ESTABLISHED",
+	2:  "SYN_SENT",
+	3:  "SYN_RECV",
+	4:  "FIN_WAIT1",
+	5:  "FIN_WAIT2",
+	6:  "TIME_WAIT",
+	7:  "CLOSE",
+	8:  "CLOSE_WAIT",
+	9:  "LAST_ACK",
+	10: "LISTEN",
+	11: "CLOSING",
+	12: "NEW_SYN_RECV",   // 4.4+：请求 sock（半连接）
+	13: "BOUND_INACTIVE", // 6.6+：bind 后尚未 listen/connect
+}
+
+// udp、raw、icmp 只会用到 TCP_ESTABLISHED（已 connect）与 TCP_CLOSE（未连接），
+// 按 ss 的习惯显示为 CONNECTED / UNCONN。
+var datagramStateNames = map[int64]string{
+	1: "CONNECTED",
+	7: "UNCONN",
+}
+
+// stateName 按协议返回状态名，未知的值返回 "UNDEFINED"。
+func stateName(protocol string, s int64) string {
+	if protocol != "tcp" {
+		if name, ok := datagramStateNames[s]; ok {
+			return name
+		}
+	}
+	if name, ok := tcpStateNames[s]; ok {
+		return name
+	}
+	return "UNDEFINED"
+}
+
+// unix socket 状态（socket_state，include/uapi/linux/net.h）。
+var unixStateNames = map[int64]string{
+	0: "FREE",
+	1: "UNCONNECTED",
+	2: "CONNECTING",
+	3: "CONNECTED",
+	4: "DISCONNECTING",
+}
@@ -0,0 +1,241 @@
+package socklist
+
+import (
+	"fmt"
+	"io"
+	"net/netip"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary 是一次快照的汇总，类似 ss -s，用于容量评估。
+type Summary struct {
+	Time      float64          `json:"time"`
+	Total     int              `json:"total"`
+	Protocols []ProtocolCount  `json:"protocols"`
+	TopPeers  []PeerCount      `json:"top_peers"`     // 按连接数排序的对端地址
+	TopListen []ListenCount    `json:"top_listeners"` // 按连接数排序的监听端口
+	Processes []ProcessSummary `json:"processes"`     // 按 socket 数排序的进程
+}
+
+// ProtocolCount 是某个协议/family 的 socket 数及其按状态名的分布。
+type ProtocolCount struct {
+	Protocol string         `json:"protocol"`
+	Family   string         `json:"family"`
+	Total    int            `json:"total"`
+	States   map[string]int `json:"states"`
+}
+
+// PeerCount 是与某个远端地址相连的 inet socket 数（不含监听与未连接的 socket）。
+type PeerCount struct {
+	Addr  netip.Addr `json:"addr"`
+	Count int        `json:"count"`
+}
+
+// ListenCount 是一个网络命名空间中某个协议、端口上的全部监听 socket 以及落在该端口上的连接数。
+// 同一端口的 IPv4、IPv6 监听与 SO_REUSEPORT 的多个监听合并为一项，连接只计一次。
+type ListenCount struct {
+	Protocol    string   `json:"protocol"`
+	Local       []string `json:"local"` // 各监听 socket 的本地地址，例如 ["0.0.0.0:22", "[::]:22"]
+	Port        uint16   `json:"port"`
+	Listeners   int      `json:"listeners"`
+	Connections int      `json:"connections"`
+	Processes   []string `json:"processes,omitempty"` // "comm/pid"
+}
+
+// ProcessSummary 是某个进程持有的 socket 数（按 fd 计，共享的 socket 对每个进程各计一次）。
+type ProcessSummary struct {
+	PID       int            `json:"pid"`
+	Comm      string         `json:"comm"`
+	Total     int            `json:"total"`
+	Protocols map[string]int `json:"protocols"`
+}
+
+// Summarize 汇总快照中的 socket；top 限制 TopPeers、TopListen 与 Processes 的长度，<= 0 表示不限。
+func Summarize(snap *Snapshot, top int) *Summary {
+	sum := &Summary{
+		Time:      snap.Time,
+		Total:     len(snap.Sockets),
+		Protocols: []ProtocolCount{},
+		TopPeers:  []PeerCount{},
+		TopListen: []ListenCount{},
+		Processes: []ProcessSummary{},
+	}
+
+	protos := map[string]*ProtocolCount{}
+	peers := map[netip.Addr]int{}
+	procs := map[int]*ProcessSummary{}
+	type portKey struct {
+		netns    uint64
+		protocol string
+		port     uint16
+	}
+	conns := map[portKey]int{}
+	listens := map[portKey]*ListenCount{}
+	var listenOrder []portKey
+
+	for _, s := range snap.Sockets {
+		key := s.Protocol + "/" + s.Family
+		pc := protos[key]
+		if pc == nil {
+			pc = &ProtocolCount{Protocol: s.Protocol, Family: s.Family, States: map[string]int{}}
+			protos[key] = pc
+		}
+		pc.Total++
+		pc.States[s.StateName]++
+
+		for _, p := range s.Processes {
+			ps := procs[p.PID]
+			if ps == nil {
+				ps = &ProcessSummary{PID: p.PID, Comm: p.Comm, Protocols: map[string]int{}}
+				procs[p.PID] = ps
+			}
+			ps.Total++
+			ps.Protocols[s.Protocol]++
+		}
+
+		if !isInet(s) {
+			continue
+		}
+		switch {
+		case s.StateName == "LISTEN":
+			key := portKey{s.NetNS, s.Protocol, s.LocalPort}
+			lc := listens[key]
+			if lc == nil {
+				lc = &ListenCount{Protocol: s.Protocol, Port: s.LocalPort}
+				listens[key] = lc
+				listenOrder = append(listenOrder, key)
+			}
+			lc.Listeners++
+			if local := s.Local(); !containsString(lc.Local, local) {
+				lc.Local = append(lc.Local, local)
+			}
+			for _, p := range s.Processes {
+				if proc := fmt.Sprintf("%s/%d", p.Comm, p.PID); !containsString(lc.Processes, proc) {
+					lc.Processes = append(lc.Processes, proc)
+				}
+			}
+		case s.RemoteIP.IsValid() && !s.RemoteIP.IsUnspecified():
+			peers[s.RemoteIP.Unmap()]++
+			conns[portKey{s.NetNS, s.Protocol, s.LocalPort}]++
+		}
+	}
+
+	for _, pc := range protos {
+		sum.Protocols = append(sum.Protocols, *pc)
+	}
+	sort.Slice(sum.Protocols, func(i, j int) bool {
+		a, b := sum.Protocols[i], sum.Protocols[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		return a.Family < b.Family
+	})
+
+	for addr, n := range peers {
+		sum.TopPeers = append(sum.TopPeers, PeerCount{Addr: addr, Count: n})
+	}
+	sort.Slice(sum.TopPeers, func(i, j int) bool {
+		a, b := sum.TopPeers[i], sum.TopPeers[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return a.Addr.Less(b.Addr)
+	})
+
+	for _, key := range listenOrder {
+		lc := listens[key]
+		lc.Connections = conns[key]
+		sum.TopListen = append(sum.TopListen, *lc)
+	}
+	sort.SliceStable(sum.TopListen, func(i, j int) bool {
+		a, b := sum.TopListen[i], sum.TopListen[j]
+		if a.Connections != b.Connections {
+			return a.Connections > b.Connections
+		}
+		return a.Port < b.Port
+	})
+
+	for _, ps := range procs {
+		sum.Processes = append(sum.Processes, *ps)
+	}
+	sort.Slice(sum.Processes, func(i, j int) bool {
+		a, b := sum.Processes[i], sum.Processes[j]
+		if a.Total != b.Total {
+			return a.Total > b.Total
+		}
+		return a.PID < b.PID
+	})
+
+	if top > 0 {
+		sum.TopPeers = truncate(sum.TopPeers, top)
+		sum.TopListen = truncate(sum.TopListen, top)
+		sum.Processes = truncate(sum.Processes, top)
+	}
+	return sum
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate[T any](s []T, n int) []T {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
+
+// WriteTable 以对齐的文本表格输出汇总。
+func (sum *Summary) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	ts := time.Unix(0, int64(sum.Time*1e9)).Format(time.RFC3339)
+	fmt.Fprintf(tw, "Total: %d sockets at %s\n\n", sum.Total, ts)
+
+	fmt.Fprintln(tw, "PROTO\tFAMILY\tTOTAL\tSTATES")
+	for _, pc := range sum.Protocols {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", pc.Protocol, pc.Family, pc.Total, formatCounts(pc.States))
+	}
+
+	fmt.Fprintln(tw, "\nPEER\tCONNECTIONS")
+	for _, p := range sum.TopPeers {
+		fmt.Fprintf(tw, "%s\t%d\n", p.Addr, p.Count)
+	}
+
+	fmt.Fprintln(tw, "\nLISTEN\tPROTO\tCONNECTIONS\tPROCESS")
+	for _, l := range sum.TopListen {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", strings.Join(l.Local, ","), l.Protocol, l.Connections, strings.Join(l.Processes, ","))
+	}
+
+	fmt.Fprintln(tw, "\nPID\tCOMM\tSOCKETS\tPROTOCOLS")
+	for _, p := range sum.Processes {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\n", p.PID, p.Comm, p.Total, formatCounts(p.Protocols))
+	}
+	return tw.Flush()
+}
+
+// formatCounts 把 {"LISTEN": 3, "ESTABLISHED": 10} 格式化为 "ESTABLISHED 10, LISTEN 3"。
+func formatCounts(m map[string]int) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if m[keys[i]] != m[keys[j]] {
+			return m[keys[i]] > m[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		parts[i] = fmt.Sprintf("%s %d", k, m[k])
+	}
+	return strings.Join(parts, ", ")
+}
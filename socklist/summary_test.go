@@ -0,0 +1,107 @@
+//go:build linux
+// +build linux
+
+package socklist
+
+import (
+	"bytes"
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSummarize(t *testing.T) {
+	snap, err := fixtureLister(t).Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := Summarize(snap, 0)
+	if sum.Total != 13 {
+		t.Errorf("Total = %d, want 13", sum.Total)
+	}
+
+	wantProtos := []ProtocolCount{
+		{"netlink", "netlink", 2, map[string]int{"UNCONN": 2}},
+		{"packet", "packet", 1, map[string]int{"UNCONN": 1}},
+		{"tcp", "ipv4", 3, map[string]int{"LISTEN": 1, "ESTABLISHED": 1, "TIME_WAIT": 1}},
+		{"tcp", "ipv6", 2, map[string]int{"LISTEN": 1, "ESTABLISHED": 1}},
+		{"udp", "ipv4", 2, map[string]int{"CONNECTED": 1, "UNCONN": 1}},
+		{"unix", "unix", 3, map[string]int{"LISTEN": 1, "CONNECTED": 1, "UNCONNECTED": 1}},
+	}
+	if !reflect.DeepEqual(sum.Protocols, wantProtos) {
+		t.Errorf("Protocols = %+v\nwant %+v", sum.Protocols, wantProtos)
+	}
+
+	// 映射地址按 IPv4 计；未连接与监听的 socket 不算对端
+	var peers []string
+	for _, p := range sum.TopPeers {
+		peers = append(peers, p.Addr.String())
+		if p.Count != 1 {
+			t.Errorf("peer %s count = %d, want 1", p.Addr, p.Count)
+		}
+	}
+	if want := []string{"10.0.0.1", "93.184.216.34", "127.0.0.1", "192.168.1.9"}; !reflect.DeepEqual(peers, want) {
+		t.Errorf("TopPeers = %v, want %v", peers, want)
+	}
+
+	wantListen := []ListenCount{
+		{Protocol: "tcp", Local: []string{"0.0.0.0:22"}, Port: 22, Listeners: 1, Connections: 1, Processes: []string{"sshd/1234"}},
+		{Protocol: "tcp", Local: []string{"[::]:80"}, Port: 80, Listeners: 1},
+	}
+	if !reflect.DeepEqual(sum.TopListen, wantListen) {
+		t.Errorf("TopListen = %+v\nwant %+v", sum.TopListen, wantListen)
+	}
+
+	wantProcs := []ProcessSummary{
+		{PID: 1234, Comm: "sshd", Total: 3, Protocols: map[string]int{"tcp": 1, "unix": 1, "netlink": 1}},
+		{PID: 4321, Comm: "curl", Total: 1, Protocols: map[string]int{"tcp": 1}},
+	}
+	if !reflect.DeepEqual(sum.Processes, wantProcs) {
+		t.Errorf("Processes = %+v\nwant %+v", sum.Processes, wantProcs)
+	}
+
+	top := Summarize(snap, 1)
+	if len(top.TopPeers) != 1 || len(top.TopListen) != 1 || len(top.Processes) != 1 || len(top.Protocols) != len(wantProtos) {
+		t.Errorf("Summarize(top=1) lengths = %d/%d/%d/%d", len(top.TopPeers), len(top.TopListen), len(top.Processes), len(top.Protocols))
+	}
+	if top.TopPeers[0].Addr != netip.MustParseAddr("10.0.0.1") {
+		t.Errorf("Summarize(top=1) peer = %s", top.TopPeers[0].Addr)
+	}
+}
+
+func TestSummaryWriteTable(t *testing.T) {
+	snap, err := fixtureLister(t).Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Summarize(snap, 0).WriteTable(&buf); err != nil {
+		t.Fatal(err)
+	}
+	// 按空白归一化后比较，不依赖 tabwriter 的列宽
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(buf.String(), "\n") {
+		lines[strings.Join(strings.Fields(line), " ")] = true
+	}
+	for _, want := range []string{
+		"PROTO FAMILY TOTAL STATES",
+		"netlink netlink 2 UNCONN 2",
+		"tcp ipv4 3 ESTABLISHED 1, LISTEN 1, TIME_WAIT 1",
+		"tcp ipv6 2 ESTABLISHED 1, LISTEN 1",
+		"udp ipv4 2 CONNECTED 1, UNCONN 1",
+		"unix unix 3 CONNECTED 1, LISTEN 1, UNCONNECTED 1",
+		"10.0.0.1 1",
+		"0.0.0.0:22 tcp 1 sshd/1234",
+		"[::]:80 tcp 0",
+		"1234 sshd 3 netlink 1, tcp 1, unix 1",
+		"4321 curl 1 tcp 1",
+	} {
+		if !lines[want] {
+			t.Errorf("table has no line %q:\n%s", want, buf.String())
+		}
+	}
+	if !strings.HasPrefix(buf.String(), "Total: 13 sockets at ") {
+		t.Errorf("table header = %q", strings.SplitN(buf.String(), "\n", 2)[0])
+	}
+}
@@ -0,0 +1,62 @@
+//go:build linux
+// +build linux
+
+package socklist
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WatchEvent 是 Watch 每个周期产出的一项。第一项只有 Snapshot；
+// 之后每项同时带有新的 Snapshot 以及相对上一次成功采集的 Diff。采集失败时只有 Err。
+type WatchEvent struct {
+	Snapshot *Snapshot
+	Diff     *SnapshotDiff
+	Err      error
+}
+
+// Watch 每隔 interval 采集一次快照，直到 ctx 被取消；返回的 channel 随之关闭。
+// 消费者处理过慢时不会丢事件，下一次采集会等待上一项被取走。
+func (l *Lister) Watch(ctx context.Context, interval time.Duration) <-chan WatchEvent {
+	ch := make(chan WatchEvent)
+	go func() {
+		defer close(ch)
+		if interval <= 0 {
+			select {
+			case ch <- WatchEvent{Err: errors.New("watch interval must be positive")}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var prev *Snapshot
+		for {
+			var ev WatchEvent
+			cur, err := l.Snapshot()
+			if err != nil {
+				ev.Err = err
+			} else {
+				ev.Snapshot = cur
+				if prev != nil {
+					ev.Diff = Diff(prev, cur)
+				}
+				prev = cur
+			}
+			select {
+			case ch <- ev:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
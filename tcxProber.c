@@ -1,3 +1,5 @@
+//go:build ignore
+
 // For tcx, it's certainly best method...
 #include <net/sock.h>
 #include <linux/sched.h>